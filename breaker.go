@@ -0,0 +1,88 @@
+package ollama
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// CircuitBreaker fast-fails requests with ErrCircuitOpen for the cooldown after threshold consecutive requests fail,
+// instead of letting each of them wait for a dead host to time out.  Once the cooldown passes, a single request is
+// allowed through as a probe; if it succeeds, the circuit closes, and if it fails, the circuit opens for another
+// cooldown.
+//
+// A request fails if it cannot be exchanged for a response, or if the response is a 5xx server error.  Requests that
+// are canceled by their own context do not count as failures, since they say nothing about the host.
+//
+// Like RateLimit, clients derived from this one with Apply or With share the same circuit.
+func CircuitBreaker(threshold int, cooldown time.Duration) Option {
+	cb := &circuitBreaker{threshold: threshold, cooldown: cooldown}
+	return RoundTripHook(func(next RoundTrip) RoundTrip {
+		return func(req *http.Request) (*http.Response, error) {
+			if !cb.allow(time.Now()) {
+				return nil, ErrCircuitOpen
+			}
+			rsp, err := next(req)
+			switch {
+			case err != nil && req.Context().Err() != nil:
+				cb.cancel()
+			case err != nil, rsp.StatusCode >= 500:
+				cb.failure(time.Now())
+			default:
+				cb.success()
+			}
+			return rsp, err
+		}
+	})
+}
+
+// ErrCircuitOpen is returned instead of sending a request while the circuit of a CircuitBreaker is open.
+var ErrCircuitOpen = errors.New(`circuit open; the Ollama host has been failing`)
+
+type circuitBreaker struct {
+	threshold int
+	cooldown  time.Duration
+
+	mu       sync.Mutex
+	failures int       // consecutive failures.
+	openedAt time.Time // when the circuit opened, or zero if it is closed.
+	probing  bool      // true while a probe is in flight after the cooldown.
+}
+
+// allow returns true if a request may be sent, marking it as the probe if the cooldown has passed.
+func (cb *circuitBreaker) allow(now time.Time) bool {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	switch {
+	case cb.openedAt.IsZero():
+		return true
+	case cb.probing || now.Sub(cb.openedAt) < cb.cooldown:
+		return false
+	}
+	cb.probing = true
+	return true
+}
+
+func (cb *circuitBreaker) success() {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	cb.failures, cb.openedAt, cb.probing = 0, time.Time{}, false
+}
+
+func (cb *circuitBreaker) failure(now time.Time) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	cb.failures++
+	if cb.probing || cb.failures >= cb.threshold {
+		cb.openedAt = now
+	}
+	cb.probing = false
+}
+
+// cancel releases the probe without deciding whether the host has recovered, so another request can probe it.
+func (cb *circuitBreaker) cancel() {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	cb.probing = false
+}
@@ -0,0 +1,131 @@
+package ollama
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/swdunlop/ollama-client/chat"
+)
+
+// ModelCapabilities returns the capabilities that Ollama reports for a model, such as "completion", "tools",
+// "vision", "thinking" or "insert".  The capabilities of each model are cached for each host, since they only change
+// when the model is replaced; see CacheTTL.
+func ModelCapabilities(ctx context.Context, model string) ([]string, error) {
+	info, err := showModel(ctx, model)
+	if err != nil {
+		return nil, err
+	}
+	return info.capabilities, nil
+}
+
+// modelInfo is the subset of the response from /api/show that the client uses.
+type modelInfo struct {
+	capabilities  []string
+	contextLength int
+	fetched       time.Time
+}
+
+// showModel returns the capabilities and context length of the model, cached for each host.
+func showModel(ctx context.Context, model string) (*modelInfo, error) {
+	ct := from(ctx)
+	key := modelCacheKey(ct, model)
+	if info, ok := modelCache.Load(key); ok {
+		info := info.(*modelInfo)
+		if ct.cacheTTL <= 0 || time.Since(info.fetched) < ct.cacheTTL {
+			return info, nil
+		}
+	}
+	req := struct {
+		Model string `json:"model"`
+	}{model}
+	var rsp struct {
+		Capabilities []string       `json:"capabilities"`
+		ModelInfo    map[string]any `json:"model_info"`
+	}
+	err := ct.Do(ctx, &rsp, `POST`, &req, `/api/show`)
+	if err != nil {
+		return nil, err
+	}
+	info := &modelInfo{capabilities: rsp.Capabilities, fetched: time.Now()}
+	for key, value := range rsp.ModelInfo {
+		// The context length is prefixed with the architecture of the model, such as "llama.context_length".
+		if strings.HasSuffix(key, `.context_length`) {
+			switch value := value.(type) {
+			case float64:
+				info.contextLength = int(value)
+			case json.Number:
+				n, _ := value.Int64()
+				info.contextLength = int(n)
+			}
+		}
+	}
+	modelCache.Store(key, info)
+	return info, nil
+}
+
+// modelCache maps a host URL and model name, separated by a space, to a *modelInfo.
+var modelCache sync.Map
+
+func modelCacheKey(ct *Client, model string) string { return hostURL(ct.ollamaHost) + ` ` + model }
+
+// forgetModel removes the model from the cache, since it has been replaced, such as by CreateModel or PullModel.
+func forgetModel(ctx context.Context, model string) {
+	modelCache.Delete(modelCacheKey(from(ctx), model))
+}
+
+// CacheTTL limits how long the capabilities and context length of a model are cached, as used by ModelCapabilities,
+// chat.RequireCapability and chat.AutoNumCtx.  By default, they are cached until the model is replaced by CreateModel or
+// PullModel, which is sufficient unless models are replaced by other programs, such as the Ollama CLI.
+func CacheTTL(ttl time.Duration) Option {
+	return func(ct *Client) { ct.cacheTTL = ttl }
+}
+
+// checkCapabilities returns chat.ErrMissingCapability if the model lacks any of the required capabilities.
+func checkCapabilities(ctx context.Context, model string, required []string) error {
+	if len(required) == 0 {
+		return nil
+	}
+	capabilities, err := ModelCapabilities(ctx, model)
+	if err != nil {
+		return fmt.Errorf(`%w while checking the capabilities of %q`, err, model)
+	}
+	for _, capability := range required {
+		if !slices.Contains(capabilities, capability) {
+			return fmt.Errorf(`%w: %q does not support %q`, chat.ErrMissingCapability, model, capability)
+		}
+	}
+	return nil
+}
+
+// autoNumCtx sets num_ctx to the smallest power of two that fits the estimated tokens of the request and a reserve for
+// the response, within the context length of the model.  It never reduces a num_ctx that is already set, since each
+// change reloads the model.
+func autoNumCtx(ctx context.Context, req *chat.Request) error {
+	info, err := showModel(ctx, req.Model)
+	if err != nil {
+		return fmt.Errorf(`%w while checking the context length of %q`, err, req.Model)
+	}
+	need := chat.EstimateRequestTokens(&req.Request, nil) + numCtxReserve
+	numCtx := minNumCtx
+	for numCtx < need {
+		numCtx *= 2
+	}
+	if info.contextLength > 0 {
+		numCtx = min(numCtx, info.contextLength)
+	}
+	if prev, ok := req.Options[`num_ctx`].(int); ok && prev >= numCtx {
+		return nil
+	}
+	chat.Set(`num_ctx`, numCtx)(req)
+	return nil
+}
+
+const (
+	minNumCtx     = 2048 // the default num_ctx of Ollama.
+	numCtxReserve = 1024 // tokens reserved for the response.
+)
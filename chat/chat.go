@@ -2,6 +2,15 @@
 package chat
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"strings"
+	"time"
+
 	"github.com/swdunlop/ollama-client/chat/message"
 	"github.com/swdunlop/ollama-client/chat/protocol"
 	"github.com/swdunlop/ollama-client/chat/tool"
@@ -54,6 +63,75 @@ func Toolkit(toolkit toolkit.Interface) Option {
 	}
 }
 
+// Messages adds messages to the request as they are, such as a conversation restored from storage or the Messages of an
+// ollama.Session.  Options after this one add messages after these.
+func Messages(msgs ...protocol.Message) Option {
+	return func(q *Request) { q.Messages = append(q.Messages, msgs...) }
+}
+
+// ToolErrorsFatal makes any error returned by a tool in the toolkit abort the chat, returning the error to the caller.
+// Without this option, the error is sent back to the model as the result of the tool call, which gives the model a chance
+// to correct its mistake, such as a malformed parameter, but also lets it paper over a failure that your code may need to
+// know about.
+//
+// Earlier versions of this package always aborted the chat when a tool failed; use this option to keep that behavior.
+func ToolErrorsFatal() Option {
+	return func(r *Request) { r.toolErrorsFatal = true }
+}
+
+// RequireContent makes the chat fail with ErrEmptyResponse if the final response from the model has no content and no
+// tool calls, which some models occasionally do.  This lets batch jobs detect and retry these responses.
+func RequireContent() Option {
+	return func(r *Request) { r.requireContent = true }
+}
+
+// Deadline limits how long ollama.Chat may take in total, including every request to the model and every tool call, so
+// a model that keeps calling tools cannot exceed a latency budget.  If the budget is exceeded, ollama.Chat returns the
+// last complete response from the model, if any, with ErrDeadlineExceeded.
+func Deadline(budget time.Duration) Option {
+	return func(r *Request) { r.deadline = budget }
+}
+
+// ErrDeadlineExceeded is returned when the budget from the Deadline option is exceeded.
+var ErrDeadlineExceeded = errors.New(`chat deadline exceeded`)
+
+// ErrEmptyResponse is returned when the RequireContent option is used and the model responds with no content.
+var ErrEmptyResponse = errors.New(`empty response from model`)
+
+// RequireCapability makes the chat fail with ErrMissingCapability before sending the request if the model does not
+// report the capability, such as "tools", "vision", "thinking" or "insert".  This avoids confusing failures, such as a
+// model that does not support tools ignoring them.  The capabilities of each model are looked up once and cached.
+func RequireCapability(capabilities ...string) Option {
+	return func(r *Request) { r.capabilities = append(r.capabilities, capabilities...) }
+}
+
+// ErrMissingCapability is returned when the RequireCapability option is used and the model lacks the capability.
+var ErrMissingCapability = errors.New(`model lacks a required capability`)
+
+// Stop is an error that a tool can return to end the chat immediately, without sending its results to the model.  The
+// chat returns the response with the tool calls, and no error.  The results of the other tool calls in that response
+// are dropped, so a session history or transcript does not end with an incomplete set of tool results.
+type Stop struct{}
+
+func (Stop) Error() string { return `tool stopped the chat` }
+
+// StopWhen stops handling tool calls once the predicate returns true for a response from the model, such as when its
+// content contains a final answer.  The response is returned as is, even if the model also called tools.
+func StopWhen(predicate func(*protocol.Response) bool) Option {
+	return func(r *Request) { r.stopWhen = predicate }
+}
+
+// StreamHook streams the response from the model, calling the hook with each chunk as it arrives, such as to update a
+// UI with partial content.  If the hook returns an error, the request is aborted and ollama.Chat returns the error.
+// ollama.Chat still returns the complete response, combining the chunks.
+//
+// When the model calls tools, each request in the tool loop is streamed, so the hook sees the chunks of every response
+// from the model, including those with tool calls, but not the tool messages.  Streaming tool calls requires Ollama
+// 0.8 or later.
+func StreamHook(hook func(ctx context.Context, delta *protocol.Response) error) Option {
+	return func(r *Request) { r.streamHook = hook }
+}
+
 // Tools adds tools that the model may call.
 func Tools(tools ...Tool) Option {
 	return func(r *Request) {
@@ -63,16 +141,120 @@ func Tools(tools ...Tool) Option {
 	}
 }
 
+// DecodeToolCall returns the name of the tool called by the model and decodes its arguments into T.  This is useful when
+// handling tool calls yourself instead of using a toolkit.
+func DecodeToolCall[T any](call protocol.ToolCall) (name string, args T, err error) {
+	if call.Function == nil {
+		err = fmt.Errorf(`only tool function calls are supported`)
+		return
+	}
+	name = call.Function.Name
+	err = json.Unmarshal(call.Function.Arguments, &args)
+	if err != nil {
+		err = fmt.Errorf(`%w while parsing arguments for %q`, err, name)
+	}
+	return
+}
+
 // Tool is an alias to the tool interface.
 type Tool = tool.Interface
 
+// JSON instructs the model to respond with valid JSON content.  It is a good idea to also describe the structure you expect
+// in a message, since the model is not otherwise told what that is.
+func JSON() Option { return Format(`json`) }
+
+// Format sets the format of the content of the response, such as "json".  The last Format or JSON option applied wins.
+func Format(format string) Option { return func(r *Request) { r.Format = protocol.Format(format) } }
+
+// FormatSchema constrains the content of the response to follow a JSON schema, such as one from tool.SchemaOf.  Like
+// Format, the last format option applied wins.  See ollama.ChatInto for decoding the content into a Go type.
+//
+// Types are renamed to their JSON Schema names with tool.JSONSchema, since tool.SchemaOf describes Go bools as "bool".
+func FormatSchema(schema protocol.ToolFunctionProperty) Option {
+	js, err := json.Marshal(tool.JSONSchema(schema))
+	if err != nil {
+		panic(err) // should never happen, since the schema only has strings, slices and maps.
+	}
+	return func(r *Request) { r.Format = protocol.Format(js) }
+}
+
 // Temperature affects how random the response may be.  A 0.0 temperature should effectively avoid any deviation from the most probable
 // response.  A 1.0 temperature affords some variation in responses.
 func Temperature(temperature float64) Option {
-	return requestOption(`temperature`, temperature)
+	return Set(`temperature`, temperature)
+}
+
+// Mirostat enables Mirostat sampling, which controls perplexity instead of using top-k or top-p sampling.  A mode of 0
+// disables it, 1 enables Mirostat and 2 enables Mirostat 2.0.
+func Mirostat(mode int) Option {
+	return Set(`mirostat`, mode)
+}
+
+// MirostatTau controls the balance between coherence and diversity of the output when Mirostat is enabled.  A lower value
+// results in more focused and coherent text.  Ollama defaults to 5.0.
+func MirostatTau(tau float64) Option {
+	return Set(`mirostat_tau`, tau)
+}
+
+// MirostatEta controls how quickly Mirostat responds to feedback from the generated text.  A lower learning rate results
+// in slower adjustments.  Ollama defaults to 0.1.
+func MirostatEta(eta float64) Option {
+	return Set(`mirostat_eta`, eta)
+}
+
+// RepeatPenalty, RepeatLastN, PresencePenalty and FrequencyPenalty discourage the model from repeating itself, which
+// can reduce looping or repetitive output.  They are model parameters like Temperature, and are applied during sampling.
+
+// RepeatPenalty sets how strongly to penalize repetitions of recent tokens.  A higher value, such as 1.5, penalizes
+// repetitions more strongly while a lower value, such as 0.9, is more lenient.  Ollama defaults to 1.1.
+func RepeatPenalty(penalty float64) Option {
+	return Set(`repeat_penalty`, penalty)
+}
+
+// RepeatLastN sets how far back the model looks for repetitions to penalize.  A value of 0 disables the penalty and -1
+// uses the length of the context.  Ollama defaults to 64.
+func RepeatLastN(n int) Option {
+	return Set(`repeat_last_n`, n)
+}
+
+// PresencePenalty penalizes tokens that have already appeared in the output at all, encouraging the model to move on to
+// new topics.
+func PresencePenalty(penalty float64) Option {
+	return Set(`presence_penalty`, penalty)
 }
 
-func requestOption(name string, value any) Option {
+// FrequencyPenalty penalizes tokens in proportion to how often they have already appeared in the output.
+func FrequencyPenalty(penalty float64) Option {
+	return Set(`frequency_penalty`, penalty)
+}
+
+// Think enables or disables reasoning for thinking models, like deepseek-r1.  The reasoning trace is returned in the
+// Thinking field of the response message, separate from its content.
+func Think(think bool) Option {
+	return func(r *Request) { r.Think = think }
+}
+
+// ThinkLevel sets how much reasoning a thinking model should do, as "low", "medium" or "high".  Only models with
+// reasoning budgets, such as gpt-oss, accept a level; others, like deepseek-r1 and qwen3, only accept Think.  Without
+// either option, the field is omitted, so models that do not reason are unaffected.
+func ThinkLevel(level string) Option {
+	return func(r *Request) { r.Think = level }
+}
+
+// AutoNumCtx sets the "num_ctx" parameter to fit the request, using the next power of two above the estimated tokens of
+// the messages and a reserve for the response, up to the context length of the model from Ollama.  This avoids wasting
+// memory on a large context for a short prompt, and truncating a long one.
+//
+// Changing num_ctx makes Ollama reload the model, so the size is only increased as a chat with tools grows, and a
+// stable size from Set may be faster for workloads with similar prompts.
+func AutoNumCtx() Option {
+	return func(r *Request) { r.autoNumCtx = true }
+}
+
+// Set sets a model parameter, such as "num_ctx" or "seed", for parameters that do not have their own option.
+//
+// See https://github.com/ollama/ollama/blob/main/docs/modelfile.md#valid-parameters-and-values
+func Set(name string, value any) Option {
 	return func(r *Request) {
 		if r.Options == nil {
 			r.Options = make(map[string]any)
@@ -81,10 +263,50 @@ func requestOption(name string, value any) Option {
 	}
 }
 
+// Options merges a map of model parameters into the request, like calling Set for each of them, such as a sampling
+// preset loaded from a configuration file.  Options are applied in order, so a later Set or Temperature overrides an
+// entry from the map, and a later map overrides earlier options.
+func Options(options map[string]any) Option {
+	return func(r *Request) {
+		if r.Options == nil {
+			r.Options = make(map[string]any, len(options))
+		}
+		maps.Copy(r.Options, options)
+	}
+}
+
+// BuildRequest applies the options to a new request and returns the request that would be sent to Ollama, which is
+// useful for inspecting the JSON of a request, such as the tool schemas, without sending it.
+func BuildRequest(options ...Option) *protocol.Request {
+	var req Request
+	for _, option := range options {
+		option(&req)
+	}
+	return &req.Request
+}
+
+// Dump returns the request built from the options as indented JSON, like BuildRequest, for debugging.  Unlike Debug,
+// this does not include defaults from the client, like its model.
+func Dump(options ...Option) string {
+	js, err := json.MarshalIndent(BuildRequest(options...), ``, `  `)
+	if err != nil {
+		return err.Error()
+	}
+	return string(js)
+}
+
+// Debug writes each request sent by ollama.Chat to w as indented JSON just before it is sent, including defaults from
+// the client and the messages added by tool calls.  This is more convenient than tracing HTTP requests when only the
+// shape of the request matters.
+func Debug(w io.Writer) Option {
+	return func(r *Request) { r.debug = w }
+}
+
 // An Option affects the construction of a chat request.
 type Option func(*Request)
 
-// Role influences how the model treats the content of a message.
+// Role influences how the model treats the content of a message.  Roles other than the protocol constants are sent as
+// is, such as Message(Role(`narrator`), content) for a model fine tuned with a custom role.
 type Role = protocol.Role
 
 // Request describes the structure of a chat request.  It is not generally necessary to construct this yourself,
@@ -92,13 +314,80 @@ type Role = protocol.Role
 type Request struct {
 	protocol.Request
 
-	toolkit toolkit.Interface
+	toolkit         toolkit.Interface
+	toolErrorsFatal bool
+	stopWhen        func(*protocol.Response) bool
+	requireContent  bool
+	streamHook      func(context.Context, *protocol.Response) error
+	capabilities    []string
+	autoNumCtx      bool
+	deadline        time.Duration
+	debug           io.Writer
 }
 
 // Toolkit returns the toolkit interface bound by the toolkit option.  This is used by the client.Chat function to handle tool
 // calls in the response.
 func (req *Request) Toolkit() toolkit.Interface { return req.toolkit }
 
+// ToolErrorsFatal returns true if the ToolErrorsFatal option was used, indicating that the client.Chat function should
+// return tool errors instead of sending them to the model.
+func (req *Request) ToolErrorsFatal() bool { return req.toolErrorsFatal }
+
+// CheckContent returns ErrEmptyResponse if the RequireContent option was used and the response has no content or tool
+// calls.
+func (req *Request) CheckContent(rsp *Response) error {
+	if req.requireContent && len(rsp.Message.ToolCalls) == 0 && strings.TrimSpace(rsp.Message.Content) == `` {
+		return ErrEmptyResponse
+	}
+	return nil
+}
+
+// WriteDebug writes the request to the writer from the Debug option as indented JSON, if there is one.
+func (req *Request) WriteDebug() error {
+	if req.debug == nil {
+		return nil
+	}
+	js, err := json.MarshalIndent(&req.Request, ``, `  `)
+	if err != nil {
+		return err
+	}
+	_, err = req.debug.Write(append(js, '\n'))
+	return err
+}
+
+// Deadline returns the budget from the Deadline option, or zero if there is none.
+func (req *Request) Deadline() time.Duration { return req.deadline }
+
+// AutoNumCtx returns true if the AutoNumCtx option was used.
+func (req *Request) AutoNumCtx() bool { return req.autoNumCtx }
+
+// RequiredCapabilities returns the capabilities from the RequireCapability option.
+func (req *Request) RequiredCapabilities() []string { return req.capabilities }
+
+// StreamHook returns the hook provided by the StreamHook option, or nil.
+func (req *Request) StreamHook() func(context.Context, *protocol.Response) error {
+	return req.streamHook
+}
+
+// StopWhen returns true if the predicate from the StopWhen option is satisfied by the response.
+func (req *Request) StopWhen(rsp *Response) bool { return req.stopWhen != nil && req.stopWhen(rsp) }
+
+// Validate checks for mistakes in the request that Ollama would reject with a less helpful error, returning an
+// InvalidRequestError if any are found.
+func (req *Request) Validate() error {
+	if req.Model == `` {
+		return &InvalidRequestError{`a model is required; use the chat.Model option or the ollama.Model client option`}
+	}
+	return nil
+}
+
+// An InvalidRequestError explains why a request was rejected by Validate before it was sent.
+type InvalidRequestError struct {
+	Reason string
+}
+
+func (err *InvalidRequestError) Error() string { return `invalid chat request: ` + err.Reason }
+
 // Request describes the structure of a chat request.  It is not generally necessary to construct this yourself,
 // instead, use the various options provided.
 type Response = protocol.Response
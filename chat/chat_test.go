@@ -0,0 +1,254 @@
+package chat
+
+import (
+	"encoding/json"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/swdunlop/ollama-client/chat/message"
+	"github.com/swdunlop/ollama-client/chat/protocol"
+)
+
+func TestUserMessageJSON(t *testing.T) {
+	var req Request
+	User(`hi`)(&req)
+	js, err := json.Marshal(req.Messages[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(js) != `{"role":"user","content":"hi"}` {
+		t.Fatalf(`expected {"role":"user","content":"hi"}, got %v`, string(js))
+	}
+}
+
+func TestTrimHistory(t *testing.T) {
+	messages := []protocol.Message{
+		{Role: protocol.SYSTEM, Content: `system`},
+		{Role: protocol.USER, Content: `first`},
+		{Role: protocol.ASSISTANT, Content: `calling`},
+		{Role: protocol.TOOL, Content: `result`},
+		{Role: protocol.ASSISTANT, Content: `answer`},
+		{Role: protocol.USER, Content: `second`},
+	}
+	count := func(string) int { return 1 }
+	trimmed := TrimHistory(messages, 3, count)
+	var roles []protocol.Role
+	for _, m := range trimmed {
+		roles = append(roles, m.Role)
+	}
+	expect := []protocol.Role{protocol.SYSTEM, protocol.ASSISTANT, protocol.USER}
+	if !slices.Equal(roles, expect) {
+		t.Fatalf(`expected %v, got %v`, expect, roles)
+	}
+	if trimmed = TrimHistory(messages, 0, count); len(trimmed) != 2 {
+		t.Fatalf(`expected only the system and last user message to be kept, got %v`, trimmed)
+	}
+}
+
+func TestMirostat(t *testing.T) {
+	req := BuildRequest(Mirostat(2), MirostatTau(4.5), MirostatEta(0.2))
+	if req.Options[`mirostat`] != 2 {
+		t.Errorf(`expected mirostat to be 2, got %v`, req.Options[`mirostat`])
+	}
+	if req.Options[`mirostat_tau`] != 4.5 {
+		t.Errorf(`expected mirostat_tau to be 4.5, got %v`, req.Options[`mirostat_tau`])
+	}
+	if req.Options[`mirostat_eta`] != 0.2 {
+		t.Errorf(`expected mirostat_eta to be 0.2, got %v`, req.Options[`mirostat_eta`])
+	}
+}
+
+func TestRepetition(t *testing.T) {
+	req := BuildRequest(RepeatPenalty(1.5), RepeatLastN(-1), PresencePenalty(0.5), FrequencyPenalty(0.25))
+	for key, value := range map[string]any{
+		`repeat_penalty`:    1.5,
+		`repeat_last_n`:     -1,
+		`presence_penalty`:  0.5,
+		`frequency_penalty`: 0.25,
+	} {
+		if req.Options[key] != value {
+			t.Errorf(`expected %v to be %v, got %v`, key, value, req.Options[key])
+		}
+	}
+}
+
+func TestOptions(t *testing.T) {
+	preset := map[string]any{`temperature`: 0.2, `top_k`: 20}
+	req := BuildRequest(Set(`seed`, 42), Options(preset), Temperature(0.7))
+	for key, value := range map[string]any{
+		`seed`:        42,
+		`top_k`:       20,
+		`temperature`: 0.7,
+	} {
+		if req.Options[key] != value {
+			t.Errorf(`expected %v to be %v, got %v`, key, value, req.Options[key])
+		}
+	}
+	req = BuildRequest(Temperature(0.7), Options(preset))
+	if req.Options[`temperature`] != 0.2 {
+		t.Errorf(`expected the preset to override the earlier temperature, got %v`, req.Options[`temperature`])
+	}
+	if len(preset) != 2 {
+		t.Errorf(`expected the preset to be left unchanged, got %v`, preset)
+	}
+}
+
+func TestFormat(t *testing.T) {
+	if req := BuildRequest(JSON()); req.Format != `json` {
+		t.Errorf(`expected JSON to set the json format, got %q`, req.Format)
+	}
+	if req := BuildRequest(JSON(), Format(``)); req.Format != `` {
+		t.Errorf(`expected Format to replace the json format, got %q`, req.Format)
+	}
+	if req := BuildRequest(Format(``), JSON()); req.Format != `json` {
+		t.Errorf(`expected JSON to replace the empty format, got %q`, req.Format)
+	}
+}
+
+func TestEstimateRequestTokens(t *testing.T) {
+	req := BuildRequest(System(`be brief`), User(`hello there`))
+	req.Messages = append(req.Messages, protocol.Message{
+		Role: protocol.ASSISTANT,
+		ToolCalls: []protocol.ToolCall{{Function: &protocol.ToolCallFunction{
+			Name:      `tick`,
+			Arguments: json.RawMessage(`{}`),
+		}}},
+	})
+	words := func(s string) int { return len(strings.Fields(s)) }
+	// 3 messages of overhead, 2 + 2 words of content, and 1 + 1 for the tool call.
+	if n := EstimateRequestTokens(req, words); n != 3*messageOverhead+6 {
+		t.Errorf(`expected %v tokens, got %v`, 3*messageOverhead+6, n)
+	}
+	if n := EstimateRequestTokens(req, nil); n == 0 {
+		t.Errorf(`expected the default estimate to count something`)
+	}
+}
+
+func TestAssistantToolCalls(t *testing.T) {
+	var req Request
+	Assistant(``, message.ToolCalls(protocol.ToolCall{ID: `call_1`, Function: &protocol.ToolCallFunction{
+		Name:      `tick`,
+		Arguments: json.RawMessage(`{"tz":"UTC"}`),
+	}}))(&req)
+	js, err := json.Marshal(req.Messages[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := `{"role":"assistant","content":"","tool_calls":[{"id":"call_1","function":{"name":"tick","arguments":{"tz":"UTC"}}}]}`
+	if string(js) != expect {
+		t.Errorf("expected %v\ngot %v", expect, string(js))
+	}
+}
+
+func TestThink(t *testing.T) {
+	for _, test := range []struct {
+		options []Option
+		expect  string
+	}{
+		{nil, `{"model":"m","stream":false}`},
+		{[]Option{Think(false)}, `{"model":"m","stream":false,"think":false}`},
+		{[]Option{ThinkLevel(`high`)}, `{"model":"m","stream":false,"think":"high"}`},
+	} {
+		js, err := json.Marshal(BuildRequest(append(test.options, Model(`m`))...))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(js) != test.expect {
+			t.Errorf("expected %v\ngot %v", test.expect, string(js))
+		}
+	}
+}
+
+func TestMessages(t *testing.T) {
+	history := []protocol.Message{
+		{Role: protocol.USER, Content: `hi`},
+		{Role: protocol.ASSISTANT, Content: `hello`},
+	}
+	req := BuildRequest(System(`be brief`), Messages(history...), User(`how are you?`))
+	var contents []string
+	for _, m := range req.Messages {
+		contents = append(contents, m.Content)
+	}
+	if expect := []string{`be brief`, `hi`, `hello`, `how are you?`}; !slices.Equal(contents, expect) {
+		t.Errorf(`expected %q, got %q`, expect, contents)
+	}
+	req.Messages[1].Content = `changed`
+	if history[0].Content != `hi` {
+		t.Errorf(`expected the history to be copied`)
+	}
+}
+
+func TestDump(t *testing.T) {
+	expect := "{\n  \"model\": \"llama3.1\",\n  \"messages\": [\n    {\n      \"role\": \"user\",\n      \"content\": \"hi\"\n    }\n  ],\n" +
+		"  \"options\": {\n    \"temperature\": 0\n  },\n  \"stream\": false\n}"
+	if dump := Dump(Model(`llama3.1`), User(`hi`), Temperature(0)); dump != expect {
+		t.Errorf("expected %v\ngot %v", expect, dump)
+	}
+}
+
+func TestCustomRole(t *testing.T) {
+	req := BuildRequest(Model(`storyteller`), Message(Role(`narrator`), `once upon a time`))
+	if err := (&Request{Request: *req}).Validate(); err != nil {
+		t.Fatal(err)
+	}
+	js, err := json.Marshal(req.Messages[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(js) != `{"role":"narrator","content":"once upon a time"}` {
+		t.Errorf(`expected the narrator role to be sent as is, got %v`, string(js))
+	}
+}
+
+func TestValidate(t *testing.T) {
+	var invalid *InvalidRequestError
+	err := (&Request{}).Validate()
+	if !errors.As(err, &invalid) || !strings.Contains(invalid.Reason, `model`) {
+		t.Errorf(`expected an InvalidRequestError for a missing model, got %v`, err)
+	}
+}
+
+func TestDecodeToolCall(t *testing.T) {
+	type lookup struct {
+		Key   string `json:"key"`
+		Limit int    `json:"limit"`
+	}
+	call := func(args string) protocol.ToolCall {
+		return protocol.ToolCall{Function: &protocol.ToolCallFunction{Name: `lookup`, Arguments: json.RawMessage(args)}}
+	}
+
+	name, args, err := DecodeToolCall[lookup](call(`{"key":"a","limit":3}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name != `lookup` || args != (lookup{`a`, 3}) {
+		t.Errorf(`unexpected tool call %q %#v`, name, args)
+	}
+
+	name, _, err = DecodeToolCall[lookup](call(`{"key":"a","limit":"many"}`))
+	var typeErr *json.UnmarshalTypeError
+	if !errors.As(err, &typeErr) || !strings.Contains(err.Error(), `"lookup"`) {
+		t.Errorf(`expected a type error for lookup, got %v`, err)
+	}
+	if name != `lookup` {
+		t.Errorf(`expected the name of the tool with the error, got %q`, name)
+	}
+
+	_, _, err = DecodeToolCall[lookup](protocol.ToolCall{})
+	if err == nil {
+		t.Error(`expected an error for a tool call without a function`)
+	}
+}
+
+func TestValidateStreamedTools(t *testing.T) {
+	// Ollama 0.8 and later stream tool calls, so a streamed request with tools is no longer rejected by Validate.
+	var req Request
+	Model(`llama3.1`)(&req)
+	req.Stream = true
+	req.Tools = []protocol.Tool{{Type: `function`, Function: &protocol.ToolFunction{Name: `lookup`}}}
+	if err := req.Validate(); err != nil {
+		t.Errorf(`expected a streamed request with tools to be valid, got %v`, err)
+	}
+}
@@ -0,0 +1,77 @@
+package chat
+
+import (
+	"unicode/utf8"
+
+	"github.com/swdunlop/ollama-client/chat/protocol"
+)
+
+// TrimHistory drops the oldest messages until the estimated number of tokens in the remaining messages fits within
+// maxTokens, returning the messages that were kept.  System messages, the most recent user message, and any messages
+// after it are always kept, even if that exceeds the budget.  When a message is dropped, any tool messages that
+// immediately follow it are also dropped, since they are results of its tool calls.
+//
+// Tokens are estimated using the count function; if it is nil, EstimateTokens is used.
+func TrimHistory(messages []protocol.Message, maxTokens int, count func(string) int) []protocol.Message {
+	if count == nil {
+		count = EstimateTokens
+	}
+	total := 0
+	for _, m := range messages {
+		total += count(m.Content)
+	}
+	last := len(messages)
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].Role == protocol.USER {
+			last = i
+			break
+		}
+	}
+	drop := make([]bool, len(messages))
+	for i := 0; i < last && total > maxTokens; i++ {
+		if drop[i] || messages[i].Role == protocol.SYSTEM {
+			continue
+		}
+		drop[i], total = true, total-count(messages[i].Content)
+		for j := i + 1; j < last && messages[j].Role == protocol.TOOL; j++ {
+			drop[j], total = true, total-count(messages[j].Content)
+		}
+	}
+	ret := make([]protocol.Message, 0, len(messages))
+	for i, m := range messages {
+		if !drop[i] {
+			ret = append(ret, m)
+		}
+	}
+	return ret
+}
+
+// EstimateTokens is a crude estimate of the number of tokens in a string, assuming four runes per token.  Each model
+// has its own tokenizer, so this is only suitable for keeping well clear of the context length.
+func EstimateTokens(content string) int {
+	return (utf8.RuneCountInString(content) + 3) / 4
+}
+
+// EstimateRequestTokens estimates the number of tokens in the messages of a request, such as one from BuildRequest,
+// including the arguments of tool calls and a small overhead for each message to account for the template around it.
+// This can be compared with the context length of the model to trim messages before sending the request.
+//
+// Tokens are estimated using the count function; if it is nil, EstimateTokens is used.
+func EstimateRequestTokens(req *protocol.Request, count func(string) int) int {
+	if count == nil {
+		count = EstimateTokens
+	}
+	total := 0
+	for _, m := range req.Messages {
+		total += messageOverhead + count(m.Content)
+		for _, call := range m.ToolCalls {
+			if call.Function != nil {
+				total += count(call.Function.Name) + count(string(call.Function.Arguments))
+			}
+		}
+	}
+	return total
+}
+
+// messageOverhead is roughly how many tokens a chat template adds around each message for its role and delimiters.
+const messageOverhead = 4
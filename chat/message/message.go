@@ -3,14 +3,41 @@ package message
 
 import (
 	"bytes"
+	"encoding/base64"
+	"fmt"
 	"image"
+	_ "image/jpeg" // so JPEG images can be decoded by ImageReader
 	"image/png"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
 
 	"github.com/swdunlop/ollama-client/chat/protocol"
+	"golang.org/x/image/draw"
 )
 
-// Image adds a Go image to a message by encoding it to PNG.
-func Image(img image.Image) Option {
+// Image adds a Go image to a message by encoding it to PNG, after applying any modifiers, such as ImageMaxDim.
+func Image(img image.Image, modifiers ...ImageModifier) Option {
+	for _, modifier := range modifiers {
+		img = modifier(img)
+	}
+	return PNG(encodePNG(img))
+}
+
+// Images adds several Go images to a message at once, encoding each to PNG, such as the pages of a document.
+func Images(imgs ...image.Image) Option {
+	data := make([][]byte, len(imgs))
+	for i, img := range imgs {
+		data[i] = encodePNG(img)
+	}
+	return PNGs(data...)
+}
+
+// encodePNG encodes a Go image as a PNG.
+func encodePNG(img image.Image) []byte {
 	var buf bytes.Buffer
 	// Assuming one byte per pixel, which is generally a significant overallocation.
 	bounds := img.Bounds()
@@ -19,15 +46,135 @@ func Image(img image.Image) Option {
 	if err != nil {
 		panic(err) // should never happen.
 	}
-	return PNG(buf.Bytes())
+	return buf.Bytes()
 }
 
-// PNG adds a PNG encoded image to a message, usable by multi-model models like `llava` and `bakllava`.`
+// PNG adds a PNG encoded image to a message, usable by multi-model models like `llava` and `bakllava`.`  The image
+// should be raw bytes, since it is base64 encoded when the message is sent; if it is already base64 encoded, it is
+// decoded first so it is not encoded twice.
 func PNG(png []byte) Option {
+	return PNGs(png)
+}
+
+// PNGs adds several PNG encoded images to a message at once, like PNG.
+func PNGs(data ...[]byte) Option {
+	images := make([]protocol.Image, len(data))
+	for i, png := range data {
+		images[i] = protocol.Image(decodeBase64Image(png))
+	}
+	return func(m *protocol.Message) {
+		m.Images = append(slices.Grow(m.Images, len(images)), images...)
+	}
+}
+
+// decodeBase64Image returns the decoded image if data is base64 encoded image content, otherwise it returns data.
+// Raw PNG, JPEG and WebP content is never valid base64, since it starts with bytes outside of the base64 alphabet.
+func decodeBase64Image(data []byte) []byte {
+	decoded := make([]byte, base64.StdEncoding.DecodedLen(len(data)))
+	n, err := base64.StdEncoding.Decode(decoded, bytes.TrimSpace(data))
+	if err != nil {
+		return data
+	}
+	decoded = decoded[:n]
+	switch http.DetectContentType(decoded) {
+	case `image/png`, `image/jpeg`, `image/webp`, `image/gif`:
+		return decoded
+	}
+	return data
+}
+
+// ImageFile reads an image file and adds it to a message without decoding it.  The file must be a PNG, JPEG or WebP
+// image, both by extension and content.  See ImageReader for how modifiers are applied.
+func ImageFile(path string, modifiers ...ImageModifier) (Option, error) {
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case `.png`, `.jpg`, `.jpeg`, `.webp`:
+	default:
+		return nil, fmt.Errorf(`unsupported image file extension %q for %q; expected .png, .jpg, .jpeg or .webp`, ext, path)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	option, err := ImageReader(f, modifiers...)
+	if err != nil {
+		return nil, fmt.Errorf(`%w while reading %q`, err, path)
+	}
+	return option, nil
+}
+
+// ImageReader reads an image and adds it to a message without decoding it.  The content must be a PNG, JPEG or WebP
+// image.
+//
+// If modifiers are provided, the image is decoded so they can be applied, and if any of them change the image, it is
+// encoded as a PNG like Image.  WebP images cannot be decoded, so they cannot be modified.
+func ImageReader(r io.Reader, modifiers ...ImageModifier) (Option, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	switch contentType := http.DetectContentType(data); contentType {
+	case `image/png`, `image/jpeg`, `image/webp`:
+	default:
+		return nil, fmt.Errorf(`unsupported image content type %q; expected PNG, JPEG or WebP`, contentType)
+	}
+	if len(modifiers) > 0 {
+		img, _, err := image.Decode(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf(`%w while decoding image to apply modifiers`, err)
+		}
+		modified := img
+		for _, modifier := range modifiers {
+			modified = modifier(modified)
+		}
+		if modified != img {
+			return Image(modified), nil
+		}
+	}
 	return func(m *protocol.Message) {
-		m.Images = append(m.Images, protocol.Image(png))
+		m.Images = append(m.Images, protocol.Image(data))
+	}, nil
+}
+
+// ImageMaxDim downscales images so their largest dimension is at most px pixels, preserving their aspect ratio.  Images
+// that already fit are left alone.  This is useful with vision models like `llava` that quietly degrade with large
+// images.
+func ImageMaxDim(px int) ImageModifier {
+	return func(img image.Image) image.Image {
+		bounds := img.Bounds()
+		w, h := bounds.Dx(), bounds.Dy()
+		if px <= 0 || (w <= px && h <= px) {
+			return img
+		}
+		if w >= h {
+			w, h = px, max(1, h*px/w)
+		} else {
+			w, h = max(1, w*px/h), px
+		}
+		dst := image.NewRGBA(image.Rect(0, 0, w, h))
+		draw.CatmullRom.Scale(dst, dst.Bounds(), img, bounds, draw.Src, nil)
+		return dst
 	}
 }
 
+// An ImageModifier alters an image before it is added to a message.
+type ImageModifier func(image.Image) image.Image
+
+// ToolCallID identifies the tool call that the message is responding to.
+func ToolCallID(id string) Option {
+	return func(m *protocol.Message) { m.ToolCallID = id }
+}
+
+// ToolName identifies the tool that produced the message.
+func ToolName(name string) Option {
+	return func(m *protocol.Message) { m.ToolName = name }
+}
+
+// ToolCalls adds tool calls to an assistant message, such as when replaying a conversation that used tools, so the
+// tool messages that follow it have calls to respond to.
+func ToolCalls(calls ...protocol.ToolCall) Option {
+	return func(m *protocol.Message) { m.ToolCalls = append(m.ToolCalls, calls...) }
+}
+
 // An Option improves a message when applied to it.
 type Option func(*protocol.Message)
@@ -0,0 +1,135 @@
+package message
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/swdunlop/ollama-client/chat/protocol"
+)
+
+func TestImageMaxDim(t *testing.T) {
+	for _, test := range []struct{ w, h, px, expectW, expectH int }{
+		{100, 50, 20, 20, 10},
+		{50, 100, 20, 10, 20},
+		{10, 5, 20, 10, 5},
+		{100, 1, 20, 20, 1},
+	} {
+		img := ImageMaxDim(test.px)(image.NewRGBA(image.Rect(0, 0, test.w, test.h)))
+		bounds := img.Bounds()
+		if bounds.Dx() != test.expectW || bounds.Dy() != test.expectH {
+			t.Errorf(`expected %vx%v to fit %v as %vx%v, got %vx%v`,
+				test.w, test.h, test.px, test.expectW, test.expectH, bounds.Dx(), bounds.Dy())
+		}
+	}
+}
+
+func TestPNG(t *testing.T) {
+	var buf bytes.Buffer
+	err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 1, 1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw := buf.Bytes()
+	encoded := []byte(base64.StdEncoding.EncodeToString(raw))
+	expect := `{"role":"user","content":"","images":["` + string(encoded) + `"]}`
+	for name, data := range map[string][]byte{`raw`: raw, `base64`: encoded} {
+		t.Run(name, func(t *testing.T) {
+			msg := protocol.Message{Role: `user`}
+			PNG(data)(&msg)
+			js, err := json.Marshal(msg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(js) != expect {
+				t.Errorf(`expected %v, got %v`, expect, string(js))
+			}
+		})
+	}
+}
+
+func TestImages(t *testing.T) {
+	page := func(w int) image.Image { return image.NewRGBA(image.Rect(0, 0, w, 1)) }
+	msg := protocol.Message{Role: `user`}
+	Images(page(1), page(2))(&msg)
+	PNGs(encodePNG(page(3)))(&msg)
+	if len(msg.Images) != 3 {
+		t.Fatalf(`expected 3 images, got %v`, len(msg.Images))
+	}
+	js, err := json.Marshal(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var wire struct {
+		Images []string `json:"images"`
+	}
+	err = json.Unmarshal(js, &wire)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, encoded := range wire.Images {
+		data, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			t.Fatalf(`%v while decoding image %v`, err, i)
+		}
+		img, err := png.Decode(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf(`%v while decoding image %v`, err, i)
+		}
+		if w := img.Bounds().Dx(); w != i+1 {
+			t.Errorf(`expected image %v to be %v pixels wide, got %v`, i, i+1, w)
+		}
+	}
+}
+
+func TestImageFile(t *testing.T) {
+	dir := t.TempDir()
+	data := encodePNG(image.NewRGBA(image.Rect(0, 0, 8, 4)))
+	path := filepath.Join(dir, `chart.png`)
+	err := os.WriteFile(path, data, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	option, err := ImageFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := protocol.Message{Role: `user`}
+	option(&msg)
+	if len(msg.Images) != 1 || !bytes.Equal(msg.Images[0], data) {
+		t.Errorf(`expected the file content as is, got %v images`, len(msg.Images))
+	}
+
+	option, err = ImageFile(path, ImageMaxDim(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg = protocol.Message{Role: `user`}
+	option(&msg)
+	if len(msg.Images) != 1 {
+		t.Fatalf(`expected one image, got %v`, len(msg.Images))
+	}
+	if cfg, format, err := image.DecodeConfig(bytes.NewReader(msg.Images[0])); err != nil || format != `png` || cfg.Width != 2 || cfg.Height != 1 {
+		t.Errorf(`expected a 2x1 PNG image, got %v %v %v`, format, cfg, err)
+	}
+
+	_, err = ImageFile(filepath.Join(dir, `missing.png`))
+	if !errors.Is(err, os.ErrNotExist) {
+		t.Errorf(`expected a missing file to fail with os.ErrNotExist, got %v`, err)
+	}
+	_, err = ImageFile(filepath.Join(dir, `notes.txt`))
+	if err == nil {
+		t.Error(`expected an error for a file without an image extension`)
+	}
+	_, err = ImageReader(strings.NewReader(`this is not an image`))
+	if err == nil {
+		t.Error(`expected an error for content that is not an image`)
+	}
+}
@@ -3,6 +3,7 @@ package protocol
 
 import (
 	"encoding/json"
+	"strings"
 	"time"
 )
 
@@ -17,13 +18,13 @@ type Request struct {
 	// Messages is a list of messages.
 	Messages []Message `json:"messages,omitempty"`
 
-	// Tools is a list of tools available to the model.  This may not be combined with streaming in Ollama
-	// as of 2024-08-24.
+	// Tools is a list of tools available to the model.  Ollama 0.8 and later can stream responses with tool calls;
+	// older versions cannot combine tools with streaming.
 	Tools []Tool `json:"tools,omitempty"`
 
 	// Format, if present, should be "json" to indicate that the content of the messages in the response
-	// should be JSON.
-	Format string `json:"format,omitempty"`
+	// should be JSON, or a JSON schema object that the content should follow.
+	Format Format `json:"format,omitempty"`
 
 	// Options is a map of model parameter overrides, such as temperature.
 	//
@@ -36,14 +37,26 @@ type Request struct {
 
 	// Stream tells the client to stream the response incrementally.
 	Stream bool `json:"stream"`
+
+	// Think, if present, enables or disables the reasoning of thinking models, such as deepseek-r1; their reasoning is
+	// returned separately from their content in the Thinking field of the response message.  This is either a bool, or
+	// a string level of "low", "medium" or "high" for models that support reasoning budgets, such as gpt-oss.
+	Think any `json:"think,omitempty"`
 }
 
 // A Message contains a single message sent either from the client to the model or from the model to the client.
 type Message struct {
 	Role      Role       `json:"role"`
 	Content   string     `json:"content"`
-	Images    []Image    `json:"images"`
-	ToolCalls []ToolCall `json:"tool_calls"`
+	Thinking  string     `json:"thinking,omitempty"`
+	Images    []Image    `json:"images,omitempty"`
+	ToolCalls []ToolCall `json:"tool_calls,omitempty"`
+
+	// ToolCallID identifies the tool call that a message with the tool role is responding to.
+	ToolCallID string `json:"tool_call_id,omitempty"`
+
+	// ToolName is the name of the tool that produced a message with the tool role.
+	ToolName string `json:"tool_name,omitempty"`
 }
 
 func (*Request) OllamaAPI() (string, string)   { return `POST`, `/api/chat` }
@@ -63,6 +76,27 @@ type Response struct {
 	EvalDuration       json.Number `json:"eval_duration"`
 }
 
+// Format is either the name of a format, like "json", or a JSON schema object, which is sent as is instead of as a
+// string.
+type Format string
+
+func (f Format) MarshalJSON() ([]byte, error) {
+	if strings.HasPrefix(string(f), `{`) && json.Valid([]byte(f)) {
+		return []byte(f), nil
+	}
+	return json.Marshal(string(f))
+}
+
+func (f *Format) UnmarshalJSON(js []byte) error {
+	var str string
+	if json.Unmarshal(js, &str) == nil {
+		*f = Format(str)
+		return nil
+	}
+	*f = Format(js)
+	return nil
+}
+
 // Image is a PNG encoded image.  This can be sent to multi-modal models like "llava" and "bakllava."
 type Image []byte
 
@@ -103,7 +137,8 @@ type ToolFunction struct {
 		// Required lists properties that are required to be present.
 		Required []string `json:"required,omitempty"`
 
-		// Properties is a map of property names to their type and description.
+		// Properties is a map of property names to their type and description.  encoding/json sorts map keys, so
+		// properties are always sent in the same order, which keeps the prompt stable for caching.
 		Properties map[string]ToolFunctionProperty `json:"properties,omitempty"`
 	} `json:"parameters"`
 
@@ -118,12 +153,27 @@ type ToolFunctionProperty struct {
 	// Description is an explanation of the property for the model.
 	Description string `json:"description"`
 
+	// Format refines the type of the property, such as "date-time" for a string containing a time.
+	Format string `json:"format,omitempty"`
+
 	// Enum is a list of acceptable values for properties that are enumerated.
 	Enum []string `json:"enum,omitempty"`
+
+	// Items describes the elements of an array property.
+	Items *ToolFunctionProperty `json:"items,omitempty"`
+
+	// Properties describes the properties of an object property.
+	Properties map[string]ToolFunctionProperty `json:"properties,omitempty"`
+
+	// Required lists the properties of an object property that are required to be present.
+	Required []string `json:"required,omitempty"`
 }
 
 // ToolCall describes a call by the model of a function that should have been described as available as a tool.
 type ToolCall struct {
+	// ID identifies the call, if the model or Ollama provided one, so the result can be correlated with it.
+	ID string `json:"id,omitempty"`
+
 	// Function is the function call.  Ollama only supports calling functions, as of 2024-08-24, regardless of
 	// whatever the model supports.
 	Function *ToolCallFunction `json:"function"`
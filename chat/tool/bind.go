@@ -3,9 +3,12 @@ package tool
 import (
 	"context"
 	"fmt"
+	"image"
 	"reflect"
 	"runtime"
+	"slices"
 	"strings"
+	"time"
 
 	"github.com/swdunlop/ollama-client/chat/protocol"
 )
@@ -82,63 +85,51 @@ func (t *tool) bindFunctionName(fv reflect.Value) {
 }
 
 func (t *tool) bindInputParameters(it reflect.Type) error {
-	n := it.NumField()
-	for i := 0; i < n; i++ {
-		fs := it.Field(i)
-		if !fs.IsExported() {
-			continue
-		}
-		if fs.Anonymous {
-			t.bindInputParameters(fs.Type)
-			continue
-		}
-
-		name := fs.Name
-		if json, ok := fs.Tag.Lookup(`json`); ok {
-			name = strings.SplitN(json, `,`, 2)[0]
-		}
-		if name == `` {
-			continue // ignore explicitly anonymous fields.
-		}
-
-		use := fs.Tag.Get(`use`)
-		jsonType := fs.Tag.Get(`type`)
-		if jsonType == `` {
-			switch fs.Type.Kind() {
-			case reflect.Array:
-				jsonType = `array` // TODO: of... ?
-			case reflect.Struct:
-				jsonType = `object`
-			case reflect.Map:
-				jsonType = `object` // TODO: of.., ?
-			case reflect.Int, reflect.Uint,
-				reflect.Int8, reflect.Uint8,
-				reflect.Int16, reflect.Uint16,
-				reflect.Int32, reflect.Uint32,
-				reflect.Int64, reflect.Uint64:
-				jsonType = `number`
-			case reflect.Bool:
-				jsonType = `bool`
-			case reflect.String:
-				jsonType = `string`
-			}
-		}
+	schema := schemaOf(it)
+	for name, property := range schema.Properties {
 		t.updateProperty(name, func(fp protocol.ToolFunctionProperty) protocol.ToolFunctionProperty {
-			if use != `` {
-				fp.Description = use
+			if property.Description != `` {
+				fp.Description = property.Description
 			}
 			if fp.Type == `` {
-				fp.Type = jsonType
+				fp.Type = property.Type
+			}
+			if fp.Format == `` {
+				fp.Format = property.Format
+			}
+			if fp.Items == nil {
+				fp.Items = property.Items
+			}
+			if fp.Properties == nil {
+				fp.Properties = property.Properties
 			}
 			return fp
 		})
 	}
-	return nil // TODO
+	for _, name := range schema.Required {
+		if !slices.Contains(t.spec.Function.Parameters.Required, name) {
+			t.spec.Function.Parameters.Required = append(t.spec.Function.Parameters.Required, name)
+		}
+	}
+	t.optional = append(t.optional, optionalFields(it)...)
+	return nil
+}
+
+// isTime returns true if the type is a time.Time or an Optional[time.Time].
+func isTime(t reflect.Type) bool {
+	if elem, ok := IsOptional(t); ok {
+		t = elem
+	}
+	return t == timeType
 }
 
 var (
 	contextInterface = reflect.TypeOf((*context.Context)(nil)).Elem()
 	errorInterface   = reflect.TypeOf((*error)(nil)).Elem()
+	imageInterface   = reflect.TypeOf((*image.Image)(nil)).Elem()
+	pngType          = reflect.TypeOf(PNG(nil))
+	timeType         = reflect.TypeOf(time.Time{})
+	optionalMarker   = reflect.TypeOf((*optionalValue)(nil)).Elem()
 )
 
 // wrongOutputs = fmt.Errorf(`tool functions must return content and may return an error`)
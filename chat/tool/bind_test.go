@@ -172,3 +172,96 @@ func fmtJSON(v any) string {
 	}
 	return string(js)
 }
+
+func TestInfoOf(t *testing.T) {
+	it, err := New(
+		Name(`add`),
+		Description(`adds two numbers`),
+		Func(func(ctx context.Context, in struct {
+			A int `use:"first number"`
+			B int `use:"second number"`
+		}) (int, error) {
+			return in.A + in.B, nil
+		}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, ok := InfoOf(it)
+	if !ok {
+		t.Fatal(`expected info for a tool constructed by New`)
+	}
+	if info.Name != `add` || info.Description != `adds two numbers` {
+		t.Errorf(`unexpected name and description %q, %q`, info.Name, info.Description)
+	}
+	if info.InputType.NumField() != 2 || info.ContentType.Kind() != reflect.Int {
+		t.Errorf(`unexpected input and content types %v, %v`, info.InputType, info.ContentType)
+	}
+	if !info.ExpectsContext || !info.ReturnsErrors {
+		t.Errorf(`expected the tool to expect a context and return errors`)
+	}
+}
+
+func TestOptionalRequired(t *testing.T) {
+	greet := func(q struct {
+		Name     string           `json:"Name" use:"who to greet"`
+		Greeting Optional[string] `json:"Greeting" use:"how to greet them"`
+	}) string {
+		return q.Greeting.Or(`hello`) + `, ` + q.Name
+	}
+	_, err := New(Func(greet), Description(`greets someone`), Required(`Greeting`))
+	if err == nil {
+		t.Error(`expected an error for a required Optional parameter`)
+	}
+	_, err = New(Func(greet), Description(`greets someone`), CamelNames(), Required(`greeting`))
+	if err == nil {
+		t.Error(`expected an error for a required Optional parameter after renaming`)
+	}
+	_, err = New(Func(greet), Description(`greets someone`), Required(`Name`))
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestBindRequired(t *testing.T) {
+	type Page struct {
+		Offset int `json:"offset" use:"index of the first result"`
+	}
+	search := func(q struct {
+		Query  string           `json:"query"  use:"what to search for"`
+		Limit  Optional[int]    `json:"limit"  use:"maximum number of results"`
+		Since  *time.Time       `json:"since"  use:"only find results after this time"`
+		Sort   Optional[string] `json:"sort"   use:"how to sort the results"`
+		Secret string           `json:"-"`
+		Page
+	}) []string {
+		return nil
+	}
+	it, err := New(Func(search), Description(`searches for things`), Required(`query`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	required := it.Tool().Function.Parameters.Required
+	if fmt.Sprint(required) != `[query offset]` {
+		t.Errorf(`expected query and offset to be required, got %v`, required)
+	}
+	_, err = it.Call(context.Background(), json.RawMessage(`{"query":"cats"}`))
+	if err == nil {
+		t.Error(`expected an error without the required offset`)
+	}
+	_, err = it.Call(context.Background(), json.RawMessage(`{"query":"cats","offset":0}`))
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestBindOptionalPointer(t *testing.T) {
+	_, err := New(Func(func(q struct {
+		N *Optional[int] `json:"n" use:"a number"`
+	}) int {
+		return 0
+	}), Description(`takes a pointer to an optional`))
+	if err != nil {
+		t.Fatal(err)
+	}
+}
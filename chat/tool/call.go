@@ -1,17 +1,49 @@
 package tool
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
+	"image"
+	"math"
 	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/swdunlop/ollama-client/chat/message"
+	"github.com/swdunlop/ollama-client/chat/protocol"
 )
 
 func (t *tool) Call(ctx context.Context, parameters json.RawMessage) (json.RawMessage, error) {
+	content, _, err := t.CallImages(ctx, parameters)
+	return content, err
+}
+
+func (t *tool) CallImages(ctx context.Context, parameters json.RawMessage) (json.RawMessage, []protocol.Image, error) {
+	err := t.checkRequired(parameters)
+	if err != nil {
+		return nil, nil, err
+	}
+	parameters, err = t.fixTimes(parameters)
+	if err != nil {
+		return nil, nil, err
+	}
+	decode := t.decode
+	if decode == nil {
+		decode = json.Unmarshal
+	}
 	q := reflect.New(t.inputType).Elem()
-	err := json.Unmarshal(parameters, q.Addr().Interface())
+	err = decode(parameters, q.Addr().Interface())
+	if err != nil && t.coerce {
+		if coerced, ok := t.coerceParameters(parameters); ok {
+			q = reflect.New(t.inputType).Elem()
+			err = decode(coerced, q.Addr().Interface())
+		}
+	}
 	if err != nil {
-		return nil, fmt.Errorf(`%w while parsing parameters for %q`, err, t.spec.Function.Name)
+		return nil, nil, fmt.Errorf(`%w while parsing parameters for %q`, err, t.spec.Function.Name)
 	}
 	var ret []reflect.Value
 	if t.expectsContext {
@@ -25,14 +57,198 @@ func (t *tool) Call(ctx context.Context, parameters json.RawMessage) (json.RawMe
 
 	if t.returnsErrors {
 		if err, ok := ret[1].Interface().(error); ok {
-			return nil, err
+			return nil, nil, err
 		}
 	}
 
+	if images, ok := returnedImages(ret[0]); ok {
+		return nil, images, nil
+	}
+
+	if raw, ok := ret[0].Interface().(json.RawMessage); ok && json.Valid(raw) {
+		// Already JSON, so we pass it through as is, without compacting or escaping it.
+		return raw, nil, nil
+	}
+
 	js, err := json.Marshal(ret[0].Interface())
 	if err != nil {
-		return nil, fmt.Errorf(`%w while formatting content for %q`, err, t.spec.Function.Name)
+		return nil, nil, fmt.Errorf(`%w while formatting content for %q`, err, t.spec.Function.Name)
+	}
+
+	return js, nil, nil
+}
+
+// returnedImages converts the value returned by a tool function to images, returning false if the function does not
+// return an image.Image or PNG.  Plain byte slices are not images, and are encoded as JSON like any other content.
+func returnedImages(ret reflect.Value) ([]protocol.Image, bool) {
+	var m protocol.Message
+	switch {
+	case ret.Type() == pngType:
+		if ret.Len() > 0 {
+			message.PNG(ret.Bytes())(&m)
+		}
+	case ret.Type().Implements(imageInterface):
+		if !ret.IsNil() {
+			message.Image(ret.Interface().(image.Image))(&m)
+		}
+	default:
+		return nil, false
+	}
+	return m.Images, true
+}
+
+// checkRequired ensures that the parameters from the model include every required parameter, since a missing parameter
+// would otherwise be silently passed to the function as a zero value.
+func (t *tool) checkRequired(parameters json.RawMessage) error {
+	required := t.spec.Function.Parameters.Required
+	if len(required) == 0 {
+		return nil
+	}
+	var present map[string]json.RawMessage
+	err := json.Unmarshal(parameters, &present)
+	if err != nil {
+		return fmt.Errorf(`%w while parsing parameters for %q`, err, t.spec.Function.Name)
+	}
+	for _, name := range required {
+		if _, ok := present[name]; !ok {
+			return fmt.Errorf(`missing required parameter %q for %q`, name, t.spec.Function.Name)
+		}
 	}
+	return nil
+}
+
+// fixTimes rewrites the parameters with the "date-time" format as RFC 3339 times, since models are not consistent about
+// how they format times and time.Time only accepts RFC 3339 from JSON.
+func (t *tool) fixTimes(parameters json.RawMessage) (json.RawMessage, error) {
+	var values map[string]json.RawMessage
+	for name, property := range t.spec.Function.Parameters.Properties {
+		if property.Format != `date-time` {
+			continue
+		}
+		if values == nil {
+			err := json.Unmarshal(parameters, &values)
+			if err != nil {
+				return nil, fmt.Errorf(`%w while parsing parameters for %q`, err, t.spec.Function.Name)
+			}
+		}
+		value, ok := values[name]
+		if !ok || string(value) == `null` {
+			continue
+		}
+		tm, err := parseTime(value)
+		if err != nil {
+			return nil, fmt.Errorf(`%w while parsing parameter %q for %q`, err, name, t.spec.Function.Name)
+		}
+		values[name], _ = json.Marshal(tm)
+	}
+	if values == nil {
+		return parameters, nil
+	}
+	return json.Marshal(values)
+}
+
+// coerceParameters rewrites parameters whose JSON type does not match their property type, where the value can be
+// converted, such as a number sent as a string.  It returns false if nothing needed to be rewritten.
+func (t *tool) coerceParameters(parameters json.RawMessage) (json.RawMessage, bool) {
+	var values map[string]json.RawMessage
+	if json.Unmarshal(parameters, &values) != nil {
+		return nil, false
+	}
+	changed := false
+	for name, value := range values {
+		property, ok := t.spec.Function.Parameters.Properties[name]
+		if !ok {
+			continue
+		}
+		if value, ok := coerceValue(property, value); ok {
+			values[name], changed = value, true
+		}
+	}
+	if !changed {
+		return nil, false
+	}
+	js, err := json.Marshal(values)
+	return js, err == nil
+}
+
+// coerceValue converts a JSON value to the type of the property, returning false if it cannot or does not need to.
+// Strings are converted to numbers and booleans, numbers and booleans are converted to strings, and the items of an
+// array are converted in turn.
+func coerceValue(property protocol.ToolFunctionProperty, value json.RawMessage) (json.RawMessage, bool) {
+	value = bytes.TrimSpace(value)
+	if len(value) == 0 {
+		return nil, false
+	}
+	var str string
+	isString := json.Unmarshal(value, &str) == nil
+	str = strings.TrimSpace(str)
+	switch property.Type {
+	case `number`, `integer`:
+		if isString {
+			if _, err := strconv.ParseFloat(str, 64); err == nil {
+				return json.RawMessage(str), true
+			}
+		}
+	case `bool`, `boolean`:
+		if isString {
+			if b, err := strconv.ParseBool(str); err == nil {
+				return json.RawMessage(strconv.FormatBool(b)), true
+			}
+		}
+	case `string`:
+		switch value[0] {
+		case '-', '0', '1', '2', '3', '4', '5', '6', '7', '8', '9', 't', 'f':
+			if json.Valid(value) {
+				js, _ := json.Marshal(string(value))
+				return js, true
+			}
+		}
+	case `array`:
+		var items []json.RawMessage
+		if property.Items == nil || json.Unmarshal(value, &items) != nil {
+			return nil, false
+		}
+		changed := false
+		for i, item := range items {
+			if item, ok := coerceValue(*property.Items, item); ok {
+				items[i], changed = item, true
+			}
+		}
+		if changed {
+			js, err := json.Marshal(items)
+			return js, err == nil
+		}
+	}
+	return nil, false
+}
+
+// parseTime parses a time from JSON, which may be a string in one of the timeFormats or a Unix timestamp in seconds,
+// either as a number or a string.
+func parseTime(value json.RawMessage) (time.Time, error) {
+	var str string
+	if json.Unmarshal(value, &str) != nil {
+		str = string(value)
+	}
+	str = strings.TrimSpace(str)
+	for _, format := range timeFormats {
+		tm, err := time.Parse(format, str)
+		if err == nil {
+			return tm, nil
+		}
+	}
+	if unix, err := strconv.ParseFloat(str, 64); err == nil {
+		sec, frac := math.Modf(unix)
+		return time.Unix(int64(sec), int64(frac*1e9)).UTC(), nil
+	}
+	return time.Time{}, fmt.Errorf(`cannot parse %q as a time; use RFC 3339, such as "2006-01-02T15:04:05Z"`, str)
+}
 
-	return js, nil
+var timeFormats = []string{
+	time.RFC3339Nano,
+	`2006-01-02T15:04:05`,
+	`2006-01-02 15:04:05Z07:00`,
+	`2006-01-02 15:04:05`,
+	`2006-01-02T15:04`,
+	`2006-01-02 15:04`,
+	time.DateOnly,
 }
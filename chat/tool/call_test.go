@@ -3,7 +3,10 @@ package tool
 import (
 	"context"
 	"encoding/json"
+	"fmt"
+	"strconv"
 	"testing"
+	"time"
 )
 
 func TestCall(t *testing.T) {
@@ -21,6 +24,143 @@ func TestCall(t *testing.T) {
 	}
 }
 
+func TestCallMissingRequired(t *testing.T) {
+	tool, err := New(Func(hello), Description("says hello to someone"), Required(`name`))
+	if err != nil {
+		t.Fatalf(`hello should be a valid tool; got %v`, err)
+	}
+	_, err = tool.Call(context.Background(), json.RawMessage(`{}`))
+	if err == nil {
+		t.Fatal(`expected an error for the missing name parameter`)
+	}
+	t.Log(`err`, err)
+}
+
+func TestCallTime(t *testing.T) {
+	tool, err := New(Func(since), Description("formats the time since a date"))
+	if err != nil {
+		t.Fatalf(`since should be a valid tool; got %v`, err)
+	}
+	if format := tool.Tool().Function.Parameters.Properties[`start`].Format; format != `date-time` {
+		t.Errorf(`expected the date-time format for start, got %q`, format)
+	}
+	for _, args := range []string{
+		`{"start": "2024-08-24", "end": "2024-08-25T00:00:00Z"}`,
+		`{"start": "2024-08-24 00:00:00", "end": 1724544000}`,
+		`{"start": "1724457600"}`,
+	} {
+		ret, err := tool.Call(context.Background(), json.RawMessage(args))
+		if err != nil {
+			t.Fatalf(`%v while calling with %v`, err, args)
+		}
+		if string(ret) != `"24h0m0s"` {
+			t.Errorf(`expected "24h0m0s" from %v, got %v`, args, string(ret))
+		}
+	}
+}
+
+func since(q struct {
+	Start time.Time           `json:"start" use:"start time"`
+	End   Optional[time.Time] `json:"end"   use:"end time"`
+}) string {
+	end := time.Date(2024, 8, 25, 0, 0, 0, 0, time.UTC)
+	if q.End.Present() {
+		end = q.End.Value()
+	}
+	return end.Sub(q.Start).String()
+}
+
+func TestCallPointers(t *testing.T) {
+	tool, err := New(Func(pointers), Description("describes which pointers are present"))
+	if err != nil {
+		t.Fatalf(`pointers should be a valid tool; got %v`, err)
+	}
+	properties := tool.Tool().Function.Parameters.Properties
+	for name, expect := range map[string]string{`n`: `number`, `s`: `string`, `t`: `string`} {
+		if properties[name].Type != expect {
+			t.Errorf(`expected %v to have type %v, got %v`, name, expect, properties[name].Type)
+		}
+	}
+	if properties[`t`].Format != `date-time` {
+		t.Errorf(`expected t to have the date-time format, got %v`, properties[`t`].Format)
+	}
+	for args, expect := range map[string]string{
+		`{}`:                                   `"n=nil s=nil t=nil"`,
+		`{"n": 42, "s": "hi"}`:                 `"n=42 s=hi t=nil"`,
+		`{"t": "2024-08-24", "s": null}`:       `"n=nil s=nil t=2024"`,
+		`{"n": 0, "s": "", "t": "2024-08-24"}`: `"n=0 s= t=2024"`,
+	} {
+		ret, err := tool.Call(context.Background(), json.RawMessage(args))
+		if err != nil {
+			t.Fatalf(`%v while calling with %v`, err, args)
+		}
+		if string(ret) != expect {
+			t.Errorf(`expected %v from %v, got %v`, expect, args, string(ret))
+		}
+	}
+}
+
+func pointers(q struct {
+	N *int       `json:"n" use:"a number"`
+	S *string    `json:"s" use:"a string"`
+	T *time.Time `json:"t" use:"a time"`
+}) string {
+	n, s, t := `nil`, `nil`, `nil`
+	if q.N != nil {
+		n = strconv.Itoa(*q.N)
+	}
+	if q.S != nil {
+		s = *q.S
+	}
+	if q.T != nil {
+		t = strconv.Itoa(q.T.Year())
+	}
+	return `n=` + n + ` s=` + s + ` t=` + t
+}
+
+func TestCallRawMessage(t *testing.T) {
+	tool, err := New(Func(raw), Description("returns pre-formatted JSON"))
+	if err != nil {
+		t.Fatalf(`raw should be a valid tool; got %v`, err)
+	}
+	ret, err := tool.Call(context.Background(), json.RawMessage(`{}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(ret) != `{ "hello": "world" }` {
+		t.Fatalf(`expected the JSON to be returned as is, got %v`, string(ret))
+	}
+}
+
+func raw(q struct{}) json.RawMessage { return json.RawMessage(`{ "hello": "world" }`) }
+
+func TestCallImages(t *testing.T) {
+	png := []byte("\x89PNG\r\n\x1a\n")
+	tool, err := New(Func(func(struct{}) PNG { return png }), Description(`returns a PNG`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, images, err := tool.(ImageCaller).CallImages(context.Background(), json.RawMessage(`{}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if content != nil || len(images) != 1 || string(images[0]) != string(png) {
+		t.Errorf(`expected the PNG as an image without content, got %q and %q`, content, images)
+	}
+
+	tool, err = New(Func(func(struct{}) []byte { return []byte(`text`) }), Description(`returns bytes`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, images, err = tool.(ImageCaller).CallImages(context.Background(), json.RawMessage(`{}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != `"dGV4dA=="` || images != nil {
+		t.Errorf(`expected plain bytes to be encoded as JSON content, got %q and %q`, content, images)
+	}
+}
+
 func hello( /* ctx context.Context, */ q struct {
 	Name string `json:"name" use:"who should we say hello to?"`
 }) (r struct {
@@ -29,3 +169,58 @@ func hello( /* ctx context.Context, */ q struct {
 	r.Hello = q.Name
 	return
 }
+
+func TestCallCoerce(t *testing.T) {
+	repeat := func(q struct {
+		Text  string  `json:"text"  use:"text to repeat"`
+		Count int     `json:"count" use:"number of times to repeat the text"`
+		Loud  bool    `json:"loud"  use:"true if the text should be shouted"`
+		Marks []int   `json:"marks" use:"positions to mark"`
+		Scale float64 `json:"scale" use:"scale of the text"`
+	}) string {
+		return fmt.Sprintf(`%v %v %v %v %v`, q.Text, q.Count, q.Loud, q.Marks, q.Scale)
+	}
+	args := json.RawMessage(`{"text": 42, "count": "3", "loud": "true", "marks": ["1", 2], "scale": " 1.5 "}`)
+
+	strict, err := New(Func(repeat), Description(`repeats text`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = strict.Call(context.Background(), args)
+	if err == nil {
+		t.Error(`expected an error without Coerce`)
+	}
+
+	lenient, err := New(Func(repeat), Description(`repeats text`), Coerce())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ret, err := lenient.Call(context.Background(), args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(ret) != `"42 3 true [1 2] 1.5"` {
+		t.Errorf(`unexpected result %v`, string(ret))
+	}
+}
+
+func TestCallDecoder(t *testing.T) {
+	var decoded []string
+	tool, err := New(Func(hello), Description(`says hello to someone`), Decoder(func(js []byte, v any) error {
+		decoded = append(decoded, string(js))
+		return json.Unmarshal([]byte(`{"name":"decoder"}`), v)
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ret, err := tool.Call(context.Background(), json.RawMessage(`{"name":"world"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(decoded) != 1 || decoded[0] != `{"name":"world"}` {
+		t.Errorf(`expected the decoder to receive the arguments, got %q`, decoded)
+	}
+	if string(ret) != `{"hello":"decoder"}` {
+		t.Errorf(`expected the result from the decoded arguments, got %v`, string(ret))
+	}
+}
@@ -0,0 +1,106 @@
+package tool
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+
+	"github.com/swdunlop/ollama-client/chat/protocol"
+)
+
+// DiffSchema describes the differences between two tool descriptions, such as before and after changing the Go
+// function of a tool, as human readable lines, like `parameter "start" changed type from "object" to "string"`.  It
+// returns nil if the descriptions are the same.  This is useful in tests that guard against accidental changes to what
+// a model is told about a tool.
+func DiffSchema(a, b protocol.Tool) []string {
+	var diffs []string
+	diff := func(format string, args ...any) { diffs = append(diffs, fmt.Sprintf(format, args...)) }
+	if a.Type != b.Type {
+		diff(`type changed from %q to %q`, a.Type, b.Type)
+	}
+	fa, fb := a.Function, b.Function
+	if fa == nil {
+		fa = new(protocol.ToolFunction)
+	}
+	if fb == nil {
+		fb = new(protocol.ToolFunction)
+	}
+	if fa.Name != fb.Name {
+		diff(`name changed from %q to %q`, fa.Name, fb.Name)
+	}
+	if fa.Description != fb.Description {
+		diff(`description changed from %q to %q`, fa.Description, fb.Description)
+	}
+	diffProperties(diff, ``, fa.Parameters.Properties, fb.Parameters.Properties)
+	diffRequired(diff, ``, fa.Parameters.Required, fb.Parameters.Required)
+	return diffs
+}
+
+// diffProperties describes the differences between two maps of properties, in order by name.
+func diffProperties(
+	diff func(string, ...any), prefix string, a, b map[string]protocol.ToolFunctionProperty,
+) {
+	names := make([]string, 0, len(a)+len(b))
+	for name := range a {
+		names = append(names, name)
+	}
+	for name := range b {
+		if _, ok := a[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		pa, inA := a[name]
+		pb, inB := b[name]
+		path := prefix + name
+		switch {
+		case !inA:
+			diff(`parameter %q added with type %q`, path, pb.Type)
+		case !inB:
+			diff(`parameter %q removed`, path)
+		default:
+			diffProperty(diff, path, pa, pb)
+		}
+	}
+}
+
+// diffProperty describes the differences between two descriptions of the same property.
+func diffProperty(diff func(string, ...any), path string, a, b protocol.ToolFunctionProperty) {
+	if a.Type != b.Type {
+		diff(`parameter %q changed type from %q to %q`, path, a.Type, b.Type)
+	}
+	if a.Format != b.Format {
+		diff(`parameter %q changed format from %q to %q`, path, a.Format, b.Format)
+	}
+	if a.Description != b.Description {
+		diff(`parameter %q changed description from %q to %q`, path, a.Description, b.Description)
+	}
+	if !slices.Equal(a.Enum, b.Enum) {
+		diff(`parameter %q changed enum from %q to %q`, path, a.Enum, b.Enum)
+	}
+	switch {
+	case a.Items == nil && b.Items != nil:
+		diff(`parameter %q added items with type %q`, path, b.Items.Type)
+	case a.Items != nil && b.Items == nil:
+		diff(`parameter %q removed items`, path)
+	case a.Items != nil:
+		diffProperty(diff, path+`[]`, *a.Items, *b.Items)
+	}
+	diffProperties(diff, path+`.`, a.Properties, b.Properties)
+	diffRequired(diff, path+`.`, a.Required, b.Required)
+}
+
+// diffRequired describes properties that became required or optional.
+func diffRequired(diff func(string, ...any), prefix string, a, b []string) {
+	for _, name := range b {
+		if !slices.Contains(a, name) {
+			diff(`parameter %q became required`, prefix+name)
+		}
+	}
+	for _, name := range a {
+		if !slices.Contains(b, name) {
+			diff(`parameter %q became optional`, prefix+name)
+		}
+	}
+}
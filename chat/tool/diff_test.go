@@ -0,0 +1,55 @@
+package tool
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestDiffSchema(t *testing.T) {
+	before, err := New(
+		Name(`findOrders`),
+		Description(`finds orders`),
+		Func(func(q struct {
+			Customer string     `json:"customer" use:"customer ID"`
+			Status   *string    `json:"status"   use:"order status"`
+			Start    *time.Time `json:"start"    use:"start time"`
+		}) string {
+			return ``
+		}),
+		Enum(`status`, `open`, `closed`),
+		Required(`customer`),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	after, err := New(
+		Name(`findOrders`),
+		Description(`finds orders`),
+		Func(func(q struct {
+			Customer int       `json:"customer" use:"customer ID"`
+			Status   string    `json:"status"   use:"order status"`
+			Tags     *[]string `json:"tags"     use:"order tags"`
+		}) string {
+			return ``
+		}),
+		Enum(`status`, `open`, `closed`, `held`),
+		Required(`customer`, `status`),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diffs := DiffSchema(before.Tool(), before.Tool()); diffs != nil {
+		t.Errorf(`expected no differences, got %q`, diffs)
+	}
+	expect := []string{
+		`parameter "customer" changed type from "string" to "number"`,
+		`parameter "start" removed`,
+		`parameter "status" changed enum from ["open" "closed"] to ["open" "closed" "held"]`,
+		`parameter "tags" added with type "array"`,
+		`parameter "status" became required`,
+	}
+	if diffs := DiffSchema(before.Tool(), after.Tool()); !slices.Equal(diffs, expect) {
+		t.Errorf("expected %q\ngot %q", expect, diffs)
+	}
+}
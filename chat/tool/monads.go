@@ -2,6 +2,8 @@ package tool
 
 import (
 	"encoding/json"
+	"fmt"
+	"reflect"
 )
 
 // None returns an optional value where the value is absent.
@@ -16,10 +18,55 @@ type Optional[T any] struct {
 	value   T
 }
 
+// IsOptional returns the type of value wrapped by t, if t is an Optional type, which lets code using reflection, like
+// Func, unwrap optional parameters.
+func IsOptional(t reflect.Type) (elem reflect.Type, ok bool) {
+	// Only the structure itself counts; a *Optional[T] also has the method, but is described as a pointer.
+	if t.Kind() != reflect.Struct || !t.Implements(optionalMarker) {
+		return nil, false
+	}
+	return reflect.Zero(t).Interface().(optionalValue).optionalElem(), true
+}
+
+// optionalValue is implemented by every Optional type, so they can be recognized regardless of what they wrap.
+type optionalValue interface {
+	optionalElem() reflect.Type
+}
+
+func (Optional[T]) optionalElem() reflect.Type { return reflect.TypeFor[T]() }
+
 func (opt Optional[T]) Present() bool { return opt.present }
 func (opt Optional[T]) Absent() bool  { return !opt.present }
 func (opt Optional[T]) Value() T      { return opt.value }
 
+// Or returns the value if it is present, otherwise it returns the fallback.
+func (opt Optional[T]) Or(fallback T) T {
+	if opt.present {
+		return opt.value
+	}
+	return fallback
+}
+
+// Presence is implemented by Optional, and lets ExactlyOne check optional values of different types.
+type Presence interface {
+	Present() bool
+}
+
+// ExactlyOne returns an error unless exactly one of the optional values is present.  This is useful for tools with
+// mutually exclusive parameters, since the error can be returned to the model to explain its mistake.
+func ExactlyOne(opts ...Presence) error {
+	n := 0
+	for _, opt := range opts {
+		if opt.Present() {
+			n++
+		}
+	}
+	if n != 1 {
+		return fmt.Errorf(`exactly one of %v parameters must be provided, got %v`, len(opts), n)
+	}
+	return nil
+}
+
 func (opt *Optional[T]) UnmarshalJSON(js []byte) error {
 	var value T
 	err := json.Unmarshal(js, &value)
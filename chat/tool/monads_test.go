@@ -0,0 +1,49 @@
+package tool
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestOptionalOr(t *testing.T) {
+	if v := Some(42).Or(7); v != 42 {
+		t.Errorf(`expected 42, got %v`, v)
+	}
+	if v := None[int]().Or(7); v != 7 {
+		t.Errorf(`expected 7, got %v`, v)
+	}
+}
+
+func TestExactlyOne(t *testing.T) {
+	id, description := Some(42), None[string]()
+	if err := ExactlyOne(id, description); err != nil {
+		t.Error(err)
+	}
+	if err := ExactlyOne(None[int](), description); err == nil {
+		t.Error(`expected an error when neither is present`)
+	}
+	if err := ExactlyOne(id, Some(`blue widget`)); err == nil {
+		t.Error(`expected an error when both are present`)
+	}
+}
+
+func TestIsOptional(t *testing.T) {
+	for _, test := range []struct {
+		t    reflect.Type
+		elem reflect.Type
+	}{
+		{reflect.TypeFor[Optional[int]](), reflect.TypeFor[int]()},
+		{reflect.TypeFor[Optional[[]string]](), reflect.TypeFor[[]string]()},
+		{reflect.TypeFor[Optional[time.Time]](), reflect.TypeFor[time.Time]()},
+		{reflect.TypeFor[int](), nil},
+		{reflect.TypeFor[*int](), nil},
+		{reflect.TypeFor[*Optional[int]](), nil},
+		{reflect.TypeFor[struct{ present bool }](), nil},
+	} {
+		elem, ok := IsOptional(test.t)
+		if elem != test.elem || ok != (test.elem != nil) {
+			t.Errorf(`expected IsOptional(%v) to be %v, got %v %v`, test.t, test.elem, elem, ok)
+		}
+	}
+}
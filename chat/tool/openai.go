@@ -0,0 +1,86 @@
+package tool
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/swdunlop/ollama-client/chat/protocol"
+)
+
+// MarshalOpenAI encodes a tool description in the shape used by the OpenAI chat completions API, where parameters are
+// a JSON schema.  The shapes are nearly the same, but this package describes booleans as "bool", where a JSON schema
+// uses "boolean".
+func MarshalOpenAI(t protocol.Tool) ([]byte, error) {
+	if t.Function == nil {
+		return nil, fmt.Errorf(`only tool functions can be described for OpenAI`)
+	}
+	var spec openAITool
+	spec.Type = `function`
+	spec.Function.Name = t.Function.Name
+	spec.Function.Description = t.Function.Description
+	spec.Function.Parameters = protocol.ToolFunctionProperty{
+		Type:       t.Function.Parameters.Type,
+		Properties: t.Function.Parameters.Properties,
+		Required:   t.Function.Parameters.Required,
+	}
+	if spec.Function.Parameters.Type == `` {
+		spec.Function.Parameters.Type = `object`
+	}
+	spec.Function.Parameters = renameTypes(spec.Function.Parameters, `bool`, `boolean`)
+	return json.Marshal(spec)
+}
+
+// UnmarshalOpenAI decodes a tool description in the shape used by the OpenAI chat completions API, reversing
+// MarshalOpenAI.
+func UnmarshalOpenAI(js []byte) (protocol.Tool, error) {
+	var spec openAITool
+	err := json.Unmarshal(js, &spec)
+	if err != nil {
+		return protocol.Tool{}, err
+	}
+	if spec.Type != `function` {
+		return protocol.Tool{}, fmt.Errorf(`only function tools are supported, got %q`, spec.Type)
+	}
+	parameters := renameTypes(spec.Function.Parameters, `boolean`, `bool`)
+	t := protocol.Tool{Type: `function`, Function: new(protocol.ToolFunction)}
+	t.Function.Name = spec.Function.Name
+	t.Function.Description = spec.Function.Description
+	t.Function.Parameters.Type = parameters.Type
+	t.Function.Parameters.Properties = parameters.Properties
+	t.Function.Parameters.Required = parameters.Required
+	return t, nil
+}
+
+type openAITool struct {
+	Type     string `json:"type"`
+	Function struct {
+		Name        string                        `json:"name"`
+		Description string                        `json:"description,omitempty"`
+		Parameters  protocol.ToolFunctionProperty `json:"parameters"`
+	} `json:"function"`
+}
+
+// JSONSchema returns a copy of the property with JSON Schema type names, such as "boolean" instead of the "bool" used by
+// SchemaOf and Func, which is what Ollama expects for the format of a response, see chat.FormatSchema.
+func JSONSchema(p protocol.ToolFunctionProperty) protocol.ToolFunctionProperty {
+	return renameTypes(p, `bool`, `boolean`)
+}
+
+// renameTypes returns a copy of the property with the type from renamed to, including nested items and properties.
+func renameTypes(p protocol.ToolFunctionProperty, from, to string) protocol.ToolFunctionProperty {
+	if p.Type == from {
+		p.Type = to
+	}
+	if p.Items != nil {
+		items := renameTypes(*p.Items, from, to)
+		p.Items = &items
+	}
+	if p.Properties != nil {
+		properties := make(map[string]protocol.ToolFunctionProperty, len(p.Properties))
+		for name, property := range p.Properties {
+			properties[name] = renameTypes(property, from, to)
+		}
+		p.Properties = properties
+	}
+	return p
+}
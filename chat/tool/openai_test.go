@@ -0,0 +1,41 @@
+package tool
+
+import "testing"
+
+func TestOpenAI(t *testing.T) {
+	it, err := New(
+		Name(`setAlarm`),
+		Description(`sets an alarm`),
+		Func(func(q struct {
+			Hour   int    `json:"hour"   use:"hour of the alarm"`
+			Repeat bool   `json:"repeat" use:"true if the alarm repeats daily"`
+			Days   []bool `json:"days"   use:"days of the week the alarm is active"`
+		}) string {
+			return ``
+		}),
+		Required(`hour`),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	js, err := MarshalOpenAI(it.Tool())
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := `{"type":"function","function":{"name":"setAlarm","description":"sets an alarm","parameters":{` +
+		`"type":"object","description":"","properties":{` +
+		`"days":{"type":"array","description":"days of the week the alarm is active","items":{"type":"boolean","description":""}},` +
+		`"hour":{"type":"number","description":"hour of the alarm"},` +
+		`"repeat":{"type":"boolean","description":"true if the alarm repeats daily"}},` +
+		`"required":["hour","repeat","days"]}}}`
+	if string(js) != expect {
+		t.Errorf("expected %v\ngot %v", expect, string(js))
+	}
+	back, err := UnmarshalOpenAI(js)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diffs := DiffSchema(it.Tool(), back); diffs != nil {
+		t.Errorf(`expected the tool to survive a round trip, got %q`, diffs)
+	}
+}
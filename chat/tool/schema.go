@@ -0,0 +1,145 @@
+package tool
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/swdunlop/ollama-client/chat/protocol"
+)
+
+// SchemaOf describes a Go structure as an object, using the same rules as Func uses for the parameters of a tool: each
+// exported field is a property, named using the "json" struct tag, described by the "use" struct tag, and with its type
+// inferred from the Go type unless the "type" struct tag is present.
+//
+// This is useful beyond tools, such as describing the structure of a response for the Format of a chat request.
+func SchemaOf(v any) (protocol.ToolFunctionProperty, error) {
+	t := reflect.TypeOf(v)
+	for t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return protocol.ToolFunctionProperty{}, fmt.Errorf(`cannot describe %T as an object; a structure is required`, v)
+	}
+	return schemaOf(t), nil
+}
+
+// schemaOf describes the Go type as a property.
+func schemaOf(t reflect.Type) (p protocol.ToolFunctionProperty) {
+	if isTime(t) {
+		// Times are parsed leniently by Call, see parseTime.
+		p.Type, p.Format = `string`, `date-time`
+		return
+	}
+	if elem, ok := IsOptional(t); ok {
+		// Optional values are sent as their value, or omitted, so they are described by their element, like pointers.
+		return schemaOf(elem)
+	}
+	switch t.Kind() {
+	case reflect.Pointer:
+		// Pointers are optional, like Optional, and nil when the model omits them, so they are described by their element.
+		return schemaOf(t.Elem())
+	case reflect.Array, reflect.Slice:
+		items := schemaOf(t.Elem())
+		p.Type, p.Items = `array`, &items
+	case reflect.Struct:
+		p.Type = `object`
+		p.Properties = make(map[string]protocol.ToolFunctionProperty, t.NumField())
+		p.Required = bindProperties(p.Properties, t)
+	case reflect.Map:
+		p.Type = `object` // TODO: of.., ?
+	case reflect.Int, reflect.Uint,
+		reflect.Int8, reflect.Uint8,
+		reflect.Int16, reflect.Uint16,
+		reflect.Int32, reflect.Uint32,
+		reflect.Int64, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		p.Type = `number`
+	case reflect.Bool:
+		p.Type = `bool`
+	case reflect.String:
+		p.Type = `string`
+	}
+	return
+}
+
+// bindProperties adds a property for each exported field of the structure, including the fields of embedded structures,
+// and returns the names of the required properties, which are those that are neither Optional nor pointers.
+func bindProperties(properties map[string]protocol.ToolFunctionProperty, t reflect.Type) (required []string) {
+	descriptions := describe(t)
+	eachField(t, func(name string, fs reflect.StructField) {
+		if _, ok := IsOptional(fs.Type); !ok && fs.Type.Kind() != reflect.Pointer {
+			required = append(required, name)
+		}
+		p := schemaOf(fs.Type)
+		if jsonType := fs.Tag.Get(`type`); jsonType != `` {
+			p.Type, p.Format = jsonType, ``
+		}
+		p.Description = fs.Tag.Get(`use`)
+		if p.Description == `` {
+			p.Description = descriptions[name]
+		}
+		properties[name] = p
+	})
+	return
+}
+
+// optionalFields returns the names of the fields of the structure that are Optional.
+func optionalFields(t reflect.Type) (names []string) {
+	eachField(t, func(name string, fs reflect.StructField) {
+		if _, ok := IsOptional(fs.Type); ok {
+			names = append(names, name)
+		}
+	})
+	return
+}
+
+// eachField calls fn with each exported field of the structure that has a name, including the fields of embedded
+// structures, named using the "json" struct tag.
+func eachField(t reflect.Type, fn func(name string, fs reflect.StructField)) {
+	for i, n := 0, t.NumField(); i < n; i++ {
+		fs := t.Field(i)
+		if !fs.IsExported() {
+			continue
+		}
+		if fs.Anonymous {
+			ft := fs.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				eachField(ft, fn)
+				continue
+			}
+		}
+
+		name := fs.Name
+		if json, ok := fs.Tag.Lookup(`json`); ok {
+			name = strings.SplitN(json, `,`, 2)[0]
+		}
+		if name == `` || name == `-` {
+			continue // ignore explicitly anonymous fields.
+		}
+		fn(name, fs)
+	}
+}
+
+// A Describer provides descriptions for the properties of a structure, by name, for fields without a "use" struct tag.
+// This lets the doc comment of a field serve as its description without repeating it in a tag, such as with a map
+// produced by go generate.
+type Describer interface {
+	Describe() map[string]string
+}
+
+// describe returns the descriptions from the Describe method of the type, if it is a Describer.
+func describe(t reflect.Type) map[string]string {
+	switch {
+	case t.Implements(describerInterface):
+		return reflect.Zero(t).Interface().(Describer).Describe()
+	case reflect.PointerTo(t).Implements(describerInterface):
+		return reflect.New(t).Interface().(Describer).Describe()
+	}
+	return nil
+}
+
+var describerInterface = reflect.TypeOf((*Describer)(nil)).Elem()
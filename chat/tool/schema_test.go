@@ -0,0 +1,152 @@
+package tool
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestSchemaOf(t *testing.T) {
+	type Item struct {
+		SKU      string `json:"sku"      use:"stock keeping unit"`
+		Quantity int    `json:"quantity" use:"number of units"`
+	}
+	schema, err := SchemaOf(struct {
+		Items  []Item    `json:"items"  use:"items in the order"`
+		Placed time.Time `json:"placed" use:"when the order was placed"`
+		Secret string    `json:"-"`
+	}{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := `{"type":"object","description":"","properties":{` +
+		`"items":{"type":"array","description":"items in the order","items":{"type":"object","description":"","properties":{` +
+		`"quantity":{"type":"number","description":"number of units"},` +
+		`"sku":{"type":"string","description":"stock keeping unit"}},"required":["sku","quantity"]}},` +
+		`"placed":{"type":"string","description":"when the order was placed","format":"date-time"}},` +
+		`"required":["items","placed"]}`
+	if js := fmtJSON(schema); js != expect {
+		t.Errorf("expected %v\ngot %v", expect, js)
+	}
+
+	_, err = SchemaOf(42)
+	if err == nil {
+		t.Error(`expected an error describing a number as an object`)
+	}
+}
+
+type describedQuery struct {
+	City  string `json:"city"`
+	Units string `json:"units" use:"units from the tag"`
+	Days  int    `json:"days"`
+}
+
+func (describedQuery) Describe() map[string]string {
+	return map[string]string{`city`: `city to forecast`, `units`: `units from Describe`}
+}
+
+func TestSchemaOfOptional(t *testing.T) {
+	schema, err := SchemaOf(struct {
+		Count Optional[int]       `json:"count" use:"number of results"`
+		Since Optional[time.Time] `json:"since" use:"earliest result"`
+		Tags  Optional[[]string]  `json:"tags"  use:"tags to match"`
+	}{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := `{"type":"object","description":"","properties":{` +
+		`"count":{"type":"number","description":"number of results"},` +
+		`"since":{"type":"string","description":"earliest result","format":"date-time"},` +
+		`"tags":{"type":"array","description":"tags to match","items":{"type":"string","description":""}}}}`
+	if js := fmtJSON(schema); js != expect {
+		t.Errorf("expected %v\ngot %v", expect, js)
+	}
+}
+
+func TestDescribe(t *testing.T) {
+	it, err := New(
+		Func(func(q describedQuery) string { return `` }),
+		Description(`forecasts the weather`),
+		Describe(`days`, `number of days to forecast`),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	properties := it.Tool().Function.Parameters.Properties
+	for name, expect := range map[string]string{
+		`city`:  `city to forecast`,
+		`units`: `units from the tag`,
+		`days`:  `number of days to forecast`,
+	} {
+		if description := properties[name].Description; description != expect {
+			t.Errorf(`expected %q to be described as %q, got %q`, name, expect, description)
+		}
+	}
+}
+
+func TestSchemaStable(t *testing.T) {
+	build := func() string {
+		it, err := New(
+			Description(`finds orders`),
+			Func(func(q struct {
+				Zone     string   `json:"zone"     use:"shipping zone"`
+				Customer string   `json:"customer" use:"customer ID"`
+				Items    []string `json:"items"    use:"item SKUs"`
+				Placed   struct {
+					Year  int `json:"year"  use:"year placed"`
+					Month int `json:"month" use:"month placed"`
+				} `json:"placed" use:"when the order was placed"`
+			}) string {
+				return ``
+			}),
+			CamelNames(),
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return fmtJSON(it.Tool())
+	}
+	expect := build()
+	for i := 0; i < 20; i++ {
+		if js := build(); js != expect {
+			t.Fatalf("expected identical JSON for each build, got\n%v\nand\n%v", expect, js)
+		}
+	}
+}
+
+func TestFromSchema(t *testing.T) {
+	schema := json.RawMessage(`{
+		"type": "object",
+		"properties": {"city": {"type": "string", "description": "city to forecast"}},
+		"required": ["city"]
+	}`)
+	it, err := FromSchema(`forecast`, `forecasts the weather`, schema,
+		func(ctx context.Context, parameters json.RawMessage) (json.RawMessage, error) {
+			return json.RawMessage(`{"forecast":"sunny"}`), nil
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+	spec := it.Tool()
+	if spec.Function.Name != `forecast` || spec.Function.Parameters.Properties[`city`].Type != `string` ||
+		len(spec.Function.Parameters.Required) != 1 {
+		t.Errorf(`unexpected tool %v`, fmtJSON(spec))
+	}
+	ret, err := it.Call(context.Background(), json.RawMessage(`{"city":"Paris"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(ret) != `{"forecast":"sunny"}` {
+		t.Errorf(`unexpected result %v`, string(ret))
+	}
+	_, err = FromSchema(`forecast`, `forecasts the weather`, json.RawMessage(`{"type":"string"}`),
+		func(ctx context.Context, parameters json.RawMessage) (json.RawMessage, error) { return nil, nil })
+	if err == nil {
+		t.Error(`expected an error for a schema that is not an object`)
+	}
+	_, err = FromSchema(`forecast`, `forecasts the weather`, schema, nil)
+	if err == nil {
+		t.Error(`expected an error for a tool without a function`)
+	}
+}
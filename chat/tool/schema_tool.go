@@ -0,0 +1,52 @@
+package tool
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/swdunlop/ollama-client/chat/protocol"
+)
+
+// FromSchema constructs a tool from a JSON schema for its parameters and a function that handles the parameters as
+// JSON, without reflection.  This is useful when the parameters of a tool are already described by a JSON schema, such
+// as one from an OpenAPI definition.  The schema must describe an object.
+func FromSchema(
+	name, description string, schema json.RawMessage,
+	fn func(ctx context.Context, parameters json.RawMessage) (json.RawMessage, error),
+) (Interface, error) {
+	switch {
+	case name == ``:
+		return nil, fmt.Errorf(`function tools must have a name`)
+	case description == ``:
+		return nil, fmt.Errorf(`function tools should have a description`)
+	case fn == nil:
+		return nil, fmt.Errorf(`function tool %q must have a function`, name)
+	}
+	var parameters protocol.ToolFunctionProperty
+	err := json.Unmarshal(schema, &parameters)
+	if err != nil {
+		return nil, fmt.Errorf(`%w while parsing the schema for %q`, err, name)
+	}
+	if parameters.Type != `object` {
+		return nil, fmt.Errorf(`the schema for %q must describe an object, got %q`, name, parameters.Type)
+	}
+	t := &schemaTool{fn: fn}
+	t.spec.Type = `function`
+	t.spec.Function = &protocol.ToolFunction{Name: name, Description: description}
+	t.spec.Function.Parameters.Type = parameters.Type
+	t.spec.Function.Parameters.Properties = parameters.Properties
+	t.spec.Function.Parameters.Required = parameters.Required
+	return t, nil
+}
+
+type schemaTool struct {
+	spec protocol.Tool
+	fn   func(context.Context, json.RawMessage) (json.RawMessage, error)
+}
+
+func (t *schemaTool) Tool() protocol.Tool { return t.spec }
+
+func (t *schemaTool) Call(ctx context.Context, parameters json.RawMessage) (json.RawMessage, error) {
+	return t.fn(ctx, parameters)
+}
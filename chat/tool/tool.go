@@ -15,10 +15,12 @@
 package tool
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
 	"reflect"
+	"slices"
 
 	"github.com/iancoleman/strcase"
 	"github.com/swdunlop/ollama-client/chat/protocol"
@@ -54,7 +56,14 @@ func New(options ...Option) (Interface, error) {
 // second input, and should return a value and an error output.
 //
 // The public fields from that structure will be bound as parameters accepted by the tool, using the "name" and "use"
-// struct tags for the name of the parameter and its description.
+// struct tags for the name of the parameter and its description.  If the structure implements Describer, its
+// descriptions are used for fields without a "use" tag.  Parameters are required unless their fields are Optional or
+// pointers.
+//
+// The value returned by the function is encoded as JSON for the model, so a string is sent quoted.  A function that
+// already has JSON content should return it as a json.RawMessage, which is sent as is.  A function that returns an
+// image.Image or PNG has no content; instead, the image is attached to the tool message, see ImageCaller.  A function
+// that returns a plain []byte is not treated as an image, so its content is encoded as JSON, which is base64.
 func Func(fn any) Option {
 	return func(t *tool) {
 		t.err = t.bind(fn)
@@ -93,6 +102,37 @@ func Parameter(parameter, parameterType, description string) Option {
 	})
 }
 
+// Describe provides a description for the named parameter, as an alternative to the "use" struct tag.
+func Describe(parameter, description string) Option {
+	return propertyOption(parameter, func(p protocol.ToolFunctionProperty) protocol.ToolFunctionProperty {
+		p.Description = description
+		return p
+	})
+}
+
+// Decoder replaces json.Unmarshal as the function used to decode the parameters from the model into the input structure
+// of the tool function.  This is useful for tools that need to be more lenient about what the model provides.
+func Decoder(decode func(js []byte, v any) error) Option {
+	return func(t *tool) { t.decode = decode }
+}
+
+// UseNumber decodes numbers in parameters as json.Number instead of float64 when the destination is an interface, such as
+// a map[string]any, which avoids losing precision in large integers.
+func UseNumber() Option {
+	return Decoder(func(js []byte, v any) error {
+		dec := json.NewDecoder(bytes.NewReader(js))
+		dec.UseNumber()
+		return dec.Decode(v)
+	})
+}
+
+// Coerce lets the tool convert parameters that the model sent with the wrong JSON type, such as "5" for a number or
+// "true" for a bool, when they cannot be decoded as sent.  This improves reliability with smaller models that are
+// inconsistent about types.
+func Coerce() Option {
+	return func(t *tool) { t.coerce = true }
+}
+
 // CamelNames converts all parameter names to camel case after the Func and Parameter options resolve using `strcase.ToLowerCamel`.
 func CamelNames() Option {
 	return FixParameterNames(strcase.ToLowerCamel)
@@ -125,6 +165,14 @@ func FixParameterNames(fix func(string) string) Option {
 			n++
 		}
 		t.spec.Function.Parameters.Required = t.spec.Function.Parameters.Required[:n]
+		n = 0
+		for _, name := range t.optional {
+			if name = fix(name); name != `` {
+				t.optional[n] = name
+				n++
+			}
+		}
+		t.optional = t.optional[:n]
 	})
 }
 
@@ -148,10 +196,15 @@ func (t *tool) updateProperty(
 	t.spec.Function.Parameters.Properties[parameter] = p
 }
 
-// Required marks that the named parameters are required.
+// Required marks that the named parameters are required.  Func already requires the parameters bound from fields that
+// are neither Optional nor pointers, so this is only needed for parameters declared with Parameter, or pointers.
 func Required(parameters ...string) Option {
 	return func(t *tool) {
-		t.spec.Function.Parameters.Required = append(t.spec.Function.Parameters.Required, parameters...)
+		for _, name := range parameters {
+			if !slices.Contains(t.spec.Function.Parameters.Required, name) {
+				t.spec.Function.Parameters.Required = append(t.spec.Function.Parameters.Required, name)
+			}
+		}
 	}
 }
 
@@ -167,6 +220,20 @@ type Interface interface {
 	Tool() protocol.Tool
 }
 
+// An ImageCaller is a tool that can return images along with its content, such as a chart or a rendered map, for vision
+// capable models.  Tools constructed by New implement this, and the toolkit package attaches the images to the tool
+// message.  Calling such a tool with Call discards the images.
+type ImageCaller interface {
+	Interface
+
+	// CallImages calls the tool like Call, but also returns any images produced by the tool.
+	CallImages(ctx context.Context, parameters json.RawMessage) (json.RawMessage, []protocol.Image, error)
+}
+
+// PNG is PNG encoded image content that a tool function can return to attach an image to the tool message, instead of
+// encoding the content as JSON.
+type PNG []byte
+
 type tool struct {
 	spec protocol.Tool
 	fn   reflect.Value
@@ -175,6 +242,11 @@ type tool struct {
 	contentType    reflect.Type
 	expectsContext bool
 	returnsErrors  bool
+	decode         func([]byte, any) error
+	coerce         bool
+
+	// optional lists the parameters bound from Optional fields, which must not be required.
+	optional []string
 
 	fixups []Option
 	err    error
@@ -182,6 +254,37 @@ type tool struct {
 
 func (t *tool) Tool() protocol.Tool { return t.spec }
 
+// Info describes the Go function bound to a tool by Func.
+type Info struct {
+	Name        string
+	Description string
+
+	// InputType is the type of structure the parameters are decoded into, and ContentType is the type of value
+	// returned by the function.
+	InputType   reflect.Type
+	ContentType reflect.Type
+
+	// ExpectsContext is true if the function accepts a context, and ReturnsErrors is true if it returns an error.
+	ExpectsContext bool
+	ReturnsErrors  bool
+}
+
+// InfoOf describes the Go function of a tool constructed by New, returning false if the tool was not.
+func InfoOf(it Interface) (Info, bool) {
+	t, ok := it.(*tool)
+	if !ok {
+		return Info{}, false
+	}
+	return Info{
+		Name:           t.spec.Function.Name,
+		Description:    t.spec.Function.Description,
+		InputType:      t.inputType,
+		ContentType:    t.contentType,
+		ExpectsContext: t.expectsContext,
+		ReturnsErrors:  t.returnsErrors,
+	}, true
+}
+
 func (t *tool) validate() error {
 	if err := t.validateDescription(); err != nil {
 		return err
@@ -231,6 +334,9 @@ func (t *tool) validateRequired() error {
 		if !ok {
 			return fmt.Errorf(`missing required parameter %q`, name)
 		}
+		if slices.Contains(t.optional, name) {
+			return fmt.Errorf(`parameter %q is Optional, so it cannot be required`, name)
+		}
 	}
 	return nil
 }
@@ -243,7 +349,7 @@ func (t *tool) validateRequired() error {
 //
 //   - If a field type implements the Enumerated interface, the property will include the Enum() values.
 //
-//   - All properties with names are required unless they are wrapped as Optional.
+//   - All properties with names are required unless they are wrapped as Optional or are pointers.
 //
 // # Arguments:
 //
@@ -256,6 +362,8 @@ func (t *tool) validateRequired() error {
 //
 //   - Optional[T] -- the argument will be an optional parameter.
 //
+//   - *T -- the argument will be an optional parameter with the type of T, and nil if the model omits it.
+//
 // # Example
 //
 //	findOrders := (ctx context.Context, q struct {
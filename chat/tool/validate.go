@@ -0,0 +1,116 @@
+package tool
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"slices"
+
+	"github.com/swdunlop/ollama-client/chat/protocol"
+)
+
+// Validate checks parameters against the schema of the tool without calling it, returning an error if a required
+// parameter is missing, a parameter is not described by the schema, a value has the wrong JSON type, or a value is not
+// one of the values from Enum.  This is not a full JSON Schema validator, but it is useful for testing that a tool
+// describes what it expects.
+//
+// Dates are accepted as strings or numbers, like Call, and properties described only as "object", such as maps, are
+// not checked.
+func Validate(it Interface, parameters json.RawMessage) error {
+	spec := it.Tool()
+	if spec.Function == nil {
+		return fmt.Errorf(`only tool functions can be validated`)
+	}
+	err := validateObject(spec.Function.Parameters.Properties, spec.Function.Parameters.Required, parameters)
+	if err != nil {
+		return fmt.Errorf(`%w while validating parameters for %q`, err, spec.Function.Name)
+	}
+	return nil
+}
+
+func validateObject(
+	properties map[string]protocol.ToolFunctionProperty, required []string, value json.RawMessage,
+) error {
+	var values map[string]json.RawMessage
+	err := json.Unmarshal(value, &values)
+	if err != nil || values == nil {
+		return fmt.Errorf(`expected an object`)
+	}
+	for _, name := range required {
+		if _, ok := values[name]; !ok {
+			return fmt.Errorf(`missing required parameter %q`, name)
+		}
+	}
+	for name, value := range values {
+		property, ok := properties[name]
+		if !ok {
+			return fmt.Errorf(`unexpected parameter %q`, name)
+		}
+		err := validateValue(property, value)
+		if err != nil {
+			return fmt.Errorf(`%w for parameter %q`, err, name)
+		}
+	}
+	return nil
+}
+
+func validateValue(property protocol.ToolFunctionProperty, value json.RawMessage) error {
+	value = bytes.TrimSpace(value)
+	if string(value) == `null` {
+		return nil // optional values may be null.
+	}
+	var kind string
+	switch value[0] {
+	case '"':
+		kind = `string`
+	case '{':
+		kind = `object`
+	case '[':
+		kind = `array`
+	case 't', 'f':
+		kind = `bool`
+	default:
+		kind = `number`
+	}
+	ok := true
+	switch property.Type {
+	case `number`:
+		ok = kind == `number`
+	case `integer`:
+		ok = kind == `number` && !bytes.ContainsAny(value, `.eE`)
+	case `bool`, `boolean`:
+		ok = kind == `bool`
+	case `string`:
+		ok = kind == `string` || (property.Format == `date-time` && kind == `number`)
+	case `object`:
+		if property.Properties == nil {
+			return nil
+		}
+		return validateObject(property.Properties, property.Required, value)
+	case `array`:
+		var items []json.RawMessage
+		if json.Unmarshal(value, &items) != nil {
+			ok = false
+			break
+		}
+		for i, item := range items {
+			if property.Items == nil {
+				break
+			}
+			err := validateValue(*property.Items, item)
+			if err != nil {
+				return fmt.Errorf(`%w in item %v`, err, i)
+			}
+		}
+	}
+	if !ok {
+		return fmt.Errorf(`expected %v, got %s`, property.Type, value)
+	}
+	if len(property.Enum) > 0 {
+		var str string
+		if json.Unmarshal(value, &str) != nil || !slices.Contains(property.Enum, str) {
+			return fmt.Errorf(`expected one of %q, got %s`, property.Enum, value)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,45 @@
+package tool
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	it, err := New(
+		Func(func(q struct {
+			Color string    `json:"color" use:"color of the widget"`
+			Count int       `json:"count" use:"number of widgets" type:"integer"`
+			Tags  *[]string `json:"tags"  use:"tags for the widgets"`
+			Rush  *bool     `json:"rush"  use:"true if the order is urgent"`
+		}) string {
+			return q.Color
+		}),
+		Description(`orders widgets`),
+		Enum(`color`, `red`, `blue`),
+		Required(`color`, `count`),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		parameters string
+		valid      bool
+	}{
+		{`{"color":"red","count":3}`, true},
+		{`{"color":"blue","count":1,"tags":["a","b"],"rush":true}`, true},
+		{`{"color":"red","count":3,"rush":null}`, true},
+		{`{"color":"red"}`, false},
+		{`{"color":"green","count":3}`, false},
+		{`{"color":"red","count":"3"}`, false},
+		{`{"color":"red","count":3.5}`, false},
+		{`{"color":"red","count":3,"tags":[1]}`, false},
+		{`{"color":"red","count":3,"size":"large"}`, false},
+		{`[]`, false},
+	} {
+		err := Validate(it, json.RawMessage(test.parameters))
+		if (err == nil) != test.valid {
+			t.Errorf(`expected %v to be valid: %v, got %v`, test.parameters, test.valid, err)
+		}
+	}
+}
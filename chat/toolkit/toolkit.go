@@ -5,23 +5,89 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"maps"
+	"reflect"
+	"sync"
+	"time"
+	"unicode/utf8"
 
+	"github.com/iancoleman/strcase"
 	"github.com/swdunlop/ollama-client/chat/protocol"
 	"github.com/swdunlop/ollama-client/chat/tool"
 )
 
-// New constructs a new toolkit from the provided tools.
+// New constructs a new toolkit from the provided tools.  If more than one tool has the same name, calls go to the last
+// one; use NewStrict to treat this as an error.
 func New(tools ...Tool) Interface {
 	tk := new(toolkit)
 	tk.list = append([]Tool(nil), tools...)
 	tk.table = make(map[string]tool.Interface, len(tools))
 	for _, tool := range tools {
-		// TODO: nag about duplicates?
 		tk.table[tool.Tool().Function.Name] = tool
 	}
 	return tk
 }
 
+// NewStrict constructs a new toolkit like New, but returns an error if more than one tool has the same name.
+func NewStrict(tools ...Tool) (Interface, error) {
+	tk := New(tools...).(*toolkit)
+	if len(tk.table) == len(tk.list) {
+		return tk, nil
+	}
+	seen := make(map[string]struct{}, len(tk.list))
+	for _, tool := range tk.list {
+		name := tool.Tool().Function.Name
+		if _, dup := seen[name]; dup {
+			return nil, fmt.Errorf(`more than one tool is named %q`, name)
+		}
+		seen[name] = struct{}{}
+	}
+	return tk, nil
+}
+
+// FromMethods constructs a new toolkit from the exported methods of v that accept a context and a structure and return
+// content and an error.  Methods that do not have this shape are ignored.  Each tool is named after its method, converted
+// to lower camel case using `strcase.ToLowerCamel`, and receives the options found under the method name in the provided
+// map, which should include at least a Description.
+//
+// Remember that methods with pointer receivers are only found if v is a pointer.
+func FromMethods(v any, methods map[string][]tool.Option) (Interface, error) {
+	rv := reflect.ValueOf(v)
+	if !rv.IsValid() {
+		return nil, fmt.Errorf(`cannot bind methods of %T as tools`, v)
+	}
+	rt := rv.Type()
+	tools := make([]Tool, 0, rt.NumMethod())
+	for i, n := 0, rt.NumMethod(); i < n; i++ {
+		method := rt.Method(i)
+		if !isToolMethod(method.Type) {
+			continue
+		}
+		options := append([]tool.Option{tool.Name(strcase.ToLowerCamel(method.Name))}, methods[method.Name]...)
+		options = append(options, tool.Func(rv.Method(i).Interface()))
+		t, err := tool.New(options...)
+		if err != nil {
+			return nil, fmt.Errorf(`%w while binding method %q`, err, method.Name)
+		}
+		tools = append(tools, t)
+	}
+	return NewStrict(tools...)
+}
+
+// isToolMethod returns true if the method type, including its receiver, has the shape
+// `func(T, context.Context, struct) (U, error)`.
+func isToolMethod(mt reflect.Type) bool {
+	return mt.NumIn() == 3 && mt.NumOut() == 2 &&
+		mt.In(1) == contextInterface &&
+		mt.In(2).Kind() == reflect.Struct &&
+		mt.Out(1) == errorInterface
+}
+
+var (
+	contextInterface = reflect.TypeOf((*context.Context)(nil)).Elem()
+	errorInterface   = reflect.TypeOf((*error)(nil)).Elem()
+)
+
 type toolkit struct {
 	list  []Tool
 	table map[string]Tool
@@ -30,6 +96,7 @@ type toolkit struct {
 // Call calls a tool from the toolkit.
 func (tk *toolkit) Call(ctx context.Context, call protocol.ToolCall) (ret protocol.Message, err error) {
 	ret.Role = protocol.TOOL
+	ret.ToolCallID = call.ID
 	defer func() {
 		if err != nil {
 			msg := struct {
@@ -43,12 +110,18 @@ func (tk *toolkit) Call(ctx context.Context, call protocol.ToolCall) (ret protoc
 		err = fmt.Errorf(`only tool function calls are supported`)
 		return
 	}
+	ret.ToolName = call.Function.Name
 	tool := tk.table[call.Function.Name]
 	if tool == nil {
 		err = fmt.Errorf(`tool %q not found`, call.Function.Name)
 		return
 	}
-	content, err := tool.Call(ctx, call.Function.Arguments)
+	var content json.RawMessage
+	if caller, ok := tool.(imageCaller); ok {
+		content, ret.Images, err = caller.CallImages(ctx, call.Function.Arguments)
+	} else {
+		content, err = tool.Call(ctx, call.Function.Arguments)
+	}
 	if err != nil {
 		return
 	}
@@ -60,6 +133,138 @@ func (tk *toolkit) Tools() []Tool {
 	return append([]Tool(nil), tk.list...)
 }
 
+// Observe wraps a toolkit so the observer is called after each tool call with the name of the tool, the arguments from
+// the model, the result of the tool, and how long the call took.  If the call failed, the result is nil and the error is
+// provided instead.  This is useful for logging what the model asked of the tools and what they returned.
+func Observe(
+	tk Interface, observer func(name string, args, result json.RawMessage, err error, dur time.Duration),
+) Interface {
+	return &observed{tk, observer}
+}
+
+type observed struct {
+	Interface
+	observer func(name string, args, result json.RawMessage, err error, dur time.Duration)
+}
+
+func (tk *observed) Call(ctx context.Context, call protocol.ToolCall) (protocol.Message, error) {
+	var name string
+	var args json.RawMessage
+	if call.Function != nil {
+		name, args = call.Function.Name, call.Function.Arguments
+	}
+	start := time.Now()
+	msg, err := tk.Interface.Call(ctx, call)
+	dur := time.Since(start)
+	var result json.RawMessage
+	if err == nil {
+		result = json.RawMessage(msg.Content)
+	}
+	tk.observer(name, args, result, err, dur)
+	return msg, err
+}
+
+// Count wraps a toolkit so the calls to each tool and how many of them failed are counted, such as for analytics about
+// which tools the model relies on and which ones it struggles to use.  Calls for tools that are not in the toolkit are
+// counted under the name the model used.
+func Count(tk Interface) *Counter {
+	return &Counter{Interface: tk}
+}
+
+// A Counter is a toolkit that counts calls to its tools, see Count.  Counters are safe for concurrent use.
+type Counter struct {
+	Interface
+
+	mu    sync.Mutex
+	stats map[string]Stats
+}
+
+// Stats describes the calls to a tool counted by a Counter.
+type Stats struct {
+	Calls  int // Calls is the number of times the tool was called.
+	Errors int // Errors is the number of calls that returned an error.
+}
+
+func (tk *Counter) Call(ctx context.Context, call protocol.ToolCall) (protocol.Message, error) {
+	msg, err := tk.Interface.Call(ctx, call)
+	var name string
+	if call.Function != nil {
+		name = call.Function.Name
+	}
+	tk.mu.Lock()
+	defer tk.mu.Unlock()
+	if tk.stats == nil {
+		tk.stats = make(map[string]Stats)
+	}
+	stats := tk.stats[name]
+	stats.Calls++
+	if err != nil {
+		stats.Errors++
+	}
+	tk.stats[name] = stats
+	return msg, err
+}
+
+// Stats returns the counts for each tool that has been called since the counter was created or reset, by name.
+func (tk *Counter) Stats() map[string]Stats {
+	tk.mu.Lock()
+	defer tk.mu.Unlock()
+	return maps.Clone(tk.stats)
+}
+
+// Reset clears the counts.
+func (tk *Counter) Reset() {
+	tk.mu.Lock()
+	defer tk.mu.Unlock()
+	clear(tk.stats)
+}
+
+// MaxResultBytes wraps a toolkit so the content of each tool message is truncated to at most n bytes, followed by a
+// "...[truncated]" marker, which keeps a tool that returns a huge result from flooding the context of the model.
+// Content is truncated between runes, so it remains valid UTF-8, but JSON content will no longer be valid JSON.  A
+// negative n is treated as zero.
+//
+// This is a toolkit wrapper rather than a chat option, so it applies wherever the toolkit is used, for example
+// chat.Toolkit(toolkit.MaxResultBytes(tk, 4096)).
+func MaxResultBytes(tk Interface, n int) Interface {
+	return &truncated{tk, max(n, 0)}
+}
+
+type truncated struct {
+	Interface
+	limit int
+}
+
+func (tk *truncated) Call(ctx context.Context, call protocol.ToolCall) (protocol.Message, error) {
+	msg, err := tk.Interface.Call(ctx, call)
+	if len(msg.Content) > tk.limit {
+		n := tk.limit
+		for n > 0 && !utf8.RuneStart(msg.Content[n]) {
+			n--
+		}
+		msg.Content = msg.Content[:n] + truncatedMarker
+	}
+	return msg, err
+}
+
+const truncatedMarker = `...[truncated]`
+
+// Schemas returns the descriptions of each tool in the toolkit, as they would be sent to Ollama.
+func Schemas(tk Interface) []protocol.Tool {
+	tools := tk.Tools()
+	ret := make([]protocol.Tool, len(tools))
+	for i, tool := range tools {
+		ret[i] = tool.Tool()
+	}
+	return ret
+}
+
+// MarshalSchemas returns the descriptions of each tool in the toolkit as an indented JSON array, which is useful for
+// documentation, or working out why a model is not calling a tool as expected.
+func MarshalSchemas(tk Interface) ([]byte, error) {
+	return json.MarshalIndent(Schemas(tk), ``, `  `)
+}
+
 // Interface describes the toolkit interface.
 type Interface interface {
 	// Call will call the requested tool, if it exists.  It will return an error if the tool did not exist, or if
@@ -71,3 +276,5 @@ type Interface interface {
 }
 
 type Tool = tool.Interface
+
+type imageCaller = tool.ImageCaller
@@ -0,0 +1,242 @@
+package toolkit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"image"
+	"testing"
+	"time"
+
+	"github.com/swdunlop/ollama-client/chat/protocol"
+	"github.com/swdunlop/ollama-client/chat/tool"
+)
+
+func TestFromMethods(t *testing.T) {
+	tk, err := FromMethods(&greeter{}, map[string][]tool.Option{
+		`SayHello`: {tool.Description(`says hello to someone`)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tools := tk.Tools()
+	if len(tools) != 1 {
+		t.Fatalf(`expected exactly one tool, got %v`, len(tools))
+	}
+	if name := tools[0].Tool().Function.Name; name != `sayHello` {
+		t.Fatalf(`expected tool to be named sayHello, got %q`, name)
+	}
+	msg, err := tk.Call(context.Background(), protocol.ToolCall{ID: `call_1`, Function: &protocol.ToolCallFunction{
+		Name:      `sayHello`,
+		Arguments: json.RawMessage(`{"name":"world"}`),
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.ToolCallID != `call_1` || msg.ToolName != `sayHello` {
+		t.Errorf(`expected the result to be labeled with call_1 and sayHello, got %q and %q`, msg.ToolCallID, msg.ToolName)
+	}
+	if msg.Content != `"hello, world"` {
+		t.Fatalf(`expected "hello, world", got %v`, msg.Content)
+	}
+	_, err = FromMethods(nil, nil)
+	if err == nil {
+		t.Error(`expected an error for nil`)
+	}
+}
+
+type greeter struct{}
+
+func (*greeter) SayHello(ctx context.Context, q struct {
+	Name string `json:"name" use:"who should we say hello to?"`
+}) (string, error) {
+	return `hello, ` + q.Name, nil
+}
+
+// Ignored does not have the shape of a tool method, and should not be bound.
+func (*greeter) Ignored() string { return `ignored` }
+
+func TestNewStrict(t *testing.T) {
+	newTool := func(description string) Tool {
+		it, err := tool.New(tool.Name(`lookup`), tool.Description(description),
+			tool.Func(func(struct{}) string { return description }))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return it
+	}
+	_, err := NewStrict(newTool(`first`), newTool(`second`))
+	if err == nil {
+		t.Error(`expected an error for two tools named lookup`)
+	}
+	_, err = NewStrict(newTool(`first`))
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestMaxResultBytes(t *testing.T) {
+	dump, err := tool.New(tool.Name(`dump`), tool.Description(`dumps a lot of text`),
+		tool.Func(func(struct{}) string { return `héllo, world` }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	call := protocol.ToolCall{Function: &protocol.ToolCallFunction{Name: `dump`, Arguments: json.RawMessage(`{}`)}}
+	for _, test := range []struct {
+		limit  int
+		expect string
+	}{
+		{100, `"héllo, world"`},
+		{3, `"h...[truncated]`}, // the é is two bytes, so it is not split.
+		{7, `"héllo...[truncated]`},
+		{-1, `...[truncated]`},
+	} {
+		msg, err := MaxResultBytes(New(dump), test.limit).Call(context.Background(), call)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if msg.Content != test.expect {
+			t.Errorf(`expected %q with a limit of %v, got %q`, test.expect, test.limit, msg.Content)
+		}
+	}
+}
+
+func TestCallImages(t *testing.T) {
+	chart, err := tool.New(tool.Name(`chart`), tool.Description(`draws a chart`),
+		tool.Func(func(struct{}) (image.Image, error) { return image.NewGray(image.Rect(0, 0, 4, 4)), nil }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	call := protocol.ToolCall{Function: &protocol.ToolCallFunction{Name: `chart`, Arguments: json.RawMessage(`{}`)}}
+	msg, err := New(chart).Call(context.Background(), call)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.Role != protocol.TOOL || len(msg.Images) != 1 {
+		t.Fatalf(`expected a tool message with one image, got %#v`, msg)
+	}
+	if cfg, format, err := image.DecodeConfig(bytes.NewReader(msg.Images[0])); err != nil || format != `png` || cfg.Width != 4 {
+		t.Errorf(`expected a 4x4 PNG image, got %v %v %v`, format, cfg, err)
+	}
+}
+
+func TestCount(t *testing.T) {
+	check, err := tool.New(tool.Name(`check`), tool.Description(`fails for odd numbers`),
+		tool.Func(func(q struct {
+			N int `json:"n" use:"the number to check"`
+		}) (string, error) {
+			if q.N%2 != 0 {
+				return ``, fmt.Errorf(`%v is odd`, q.N)
+			}
+			return `ok`, nil
+		}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tk := Count(New(check))
+	for _, call := range []struct{ name, args string }{
+		{`check`, `{"n":2}`},
+		{`check`, `{"n":3}`},
+		{`check`, `{"n":4}`},
+		{`missing`, `{}`},
+	} {
+		_, _ = tk.Call(context.Background(), protocol.ToolCall{Function: &protocol.ToolCallFunction{
+			Name: call.name, Arguments: json.RawMessage(call.args),
+		}})
+	}
+	stats := tk.Stats()
+	if stats[`check`] != (Stats{Calls: 3, Errors: 1}) || stats[`missing`] != (Stats{Calls: 1, Errors: 1}) {
+		t.Errorf(`unexpected stats %v`, stats)
+	}
+	tk.Reset()
+	if len(tk.Stats()) != 0 {
+		t.Errorf(`expected no stats after a reset, got %v`, tk.Stats())
+	}
+}
+
+func TestObserve(t *testing.T) {
+	check, err := tool.New(tool.Name(`check`), tool.Description(`fails for odd numbers`),
+		tool.Func(func(q struct {
+			N int `json:"n" use:"the number to check"`
+		}) (string, error) {
+			if q.N%2 != 0 {
+				return ``, fmt.Errorf(`%v is odd`, q.N)
+			}
+			return `ok`, nil
+		}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	type observation struct {
+		name, args, result string
+		err                error
+	}
+	var seen []observation
+	tk := Observe(New(check), func(name string, args, result json.RawMessage, err error, dur time.Duration) {
+		if dur < 0 {
+			t.Errorf(`unexpected duration %v`, dur)
+		}
+		seen = append(seen, observation{name, string(args), string(result), err})
+	})
+	for _, args := range []string{`{"n":2}`, `{"n":3}`} {
+		_, _ = tk.Call(context.Background(), protocol.ToolCall{Function: &protocol.ToolCallFunction{
+			Name: `check`, Arguments: json.RawMessage(args),
+		}})
+	}
+	if len(seen) != 2 {
+		t.Fatalf(`expected two observations, got %v`, len(seen))
+	}
+	if seen[0] != (observation{`check`, `{"n":2}`, `"ok"`, nil}) {
+		t.Errorf(`unexpected observation of a successful call %#v`, seen[0])
+	}
+	if seen[1].name != `check` || seen[1].args != `{"n":3}` || seen[1].result != `` ||
+		seen[1].err == nil || seen[1].err.Error() != `3 is odd` {
+		t.Errorf(`unexpected observation of a failed call %#v`, seen[1])
+	}
+}
+
+func TestMarshalSchemas(t *testing.T) {
+	weather, err := tool.New(tool.Name(`weather`), tool.Description(`reports the weather`),
+		tool.Func(func(q struct {
+			City string `json:"city" use:"the city to report on"`
+		}) string {
+			return `sunny`
+		}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tk := New(weather)
+	schemas := Schemas(tk)
+	if len(schemas) != 1 || schemas[0].Function.Name != `weather` {
+		t.Fatalf(`unexpected schemas %#v`, schemas)
+	}
+	js, err := MarshalSchemas(tk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := `[
+  {
+    "type": "function",
+    "function": {
+      "name": "weather",
+      "description": "reports the weather",
+      "parameters": {
+        "type": "object",
+        "required": [
+          "city"
+        ],
+        "properties": {
+          "city": {
+            "type": "string",
+            "description": "the city to report on"
+          }
+        }
+      }
+    }
+  }
+]`
+	if string(js) != expect {
+		t.Errorf("expected %v\ngot %v", expect, string(js))
+	}
+}
@@ -3,17 +3,30 @@ package ollama
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
+	"net/url"
 	"os"
-	"strconv"
+	"runtime/debug"
+	"slices"
 	"strings"
+	"time"
+	"unicode/utf8"
 
 	"github.com/rs/zerolog"
 	"github.com/swdunlop/ollama-client/chat"
+	"github.com/swdunlop/ollama-client/chat/protocol"
+	"github.com/swdunlop/ollama-client/chat/tool"
+	"github.com/swdunlop/ollama-client/create"
 	"github.com/swdunlop/ollama-client/embed"
+	"github.com/swdunlop/ollama-client/generate"
+	"github.com/swdunlop/ollama-client/pull"
 )
 
 // With creates a new Ollama client or expands the previous one in a context.
@@ -21,32 +34,181 @@ func With(ctx context.Context, options ...Option) context.Context {
 	if len(options) == 0 {
 		return ctx
 	}
-	client := *from(ctx)
-	client.requestHooks = append([]func(*http.Request) error(nil), client.requestHooks...)
-	client.responseHooks = append([]func(*http.Response) error(nil), client.responseHooks...)
-	for _, option := range options {
-		option(&client)
-	}
-	return context.WithValue(ctx, ctxClient{}, &client)
+	return context.WithValue(ctx, ctxClient{}, from(ctx).Apply(options...))
 }
 
 // Chat does a chat request with the provided context.  If a toolkit is provided for the request, it will be used to
-// handle any tool calls.
+// handle any tool calls.  Errors from tools are sent to the model unless the chat.ToolErrorsFatal option is used, or the
+// error is chat.Stop, which ends the chat.
 func Chat(ctx context.Context, options ...chat.Option) (*chat.Response, error) {
 	req := newRequest[chat.Request](options...)
+	return doChat(ctx, req)
+}
+
+// ChatTranscript is like Chat, but also returns the messages exchanged with tools before the final response, which are
+// the tool calls from the model and the results from the tools.  This is useful for auditing what happened or resuming a
+// conversation.  The transcript is returned even if there is an error.
+func ChatTranscript(ctx context.Context, options ...chat.Option) (*chat.Response, []protocol.Message, error) {
+	req := newRequest[chat.Request](options...)
+	n := len(req.Messages)
+	rsp, err := doChat(ctx, req)
+	return rsp, req.Messages[n:], err
+}
+
+// sendChat sends a single chat request, streaming it if the chat.StreamHook option was used.
+func sendChat(ctx context.Context, req *chat.Request) (rsp chat.Response, err error) {
+	hook := req.StreamHook()
+	if hook != nil {
+		req.Stream = true
+		defer func() { req.Stream = false }()
+	}
+	err = req.WriteDebug()
+	if err != nil {
+		return
+	}
+	if hook == nil {
+		err = from(ctx).Do(ctx, &rsp, `POST`, req, `/api/chat`)
+		return
+	}
+	var content, thinking strings.Builder
+	var toolCalls []protocol.ToolCall
+	err = from(ctx).Stream(ctx, func(msg json.RawMessage) error {
+		var delta chat.Response
+		err := json.Unmarshal(msg, &delta)
+		if err != nil {
+			return err
+		}
+		content.WriteString(delta.Message.Content)
+		thinking.WriteString(delta.Message.Thinking)
+		toolCalls = append(toolCalls, delta.Message.ToolCalls...)
+		rsp = delta
+		return hook(ctx, &delta)
+	}, `POST`, req, `/api/chat`)
+	rsp.Message.Content, rsp.Message.Thinking, rsp.Message.ToolCalls = content.String(), thinking.String(), toolCalls
+	return
+}
+
+// ChatInto does a chat request, like Chat, with the response constrained to the JSON schema of T, which must be a
+// structure, then decodes the content of the response into a T.  If the content does not decode, the response is
+// returned with the error, so the caller can see what the model said.
+func ChatInto[T any](ctx context.Context, options ...chat.Option) (*T, *chat.Response, error) {
+	ret := new(T)
+	schema, err := tool.SchemaOf(ret)
+	if err != nil {
+		return nil, nil, err
+	}
+	rsp, err := Chat(ctx, append(options, chat.FormatSchema(schema))...)
+	if err != nil {
+		return nil, rsp, err
+	}
+	err = json.Unmarshal([]byte(rsp.Message.Content), ret)
+	if err != nil {
+		return nil, rsp, fmt.Errorf(`%w while decoding the response as %T`, err, ret)
+	}
+	return ret, rsp, nil
+}
+
+// ChatReader starts a streaming chat and returns a reader of the content of the response as it is generated, such as
+// for copying to a terminal or an HTTP response.  Closing the reader cancels the request.  Errors from the request are
+// returned by Read.  Only complete UTF-8 sequences are read, even if Ollama splits a rune between streamed chunks.
+//
+// Tool calls are not handled in this mode, so requests with tools are rejected; use chat.StreamHook with Chat to stream
+// a chat that calls tools.
+func ChatReader(ctx context.Context, options ...chat.Option) (io.ReadCloser, error) {
+	req := newRequest[chat.Request](options...)
+	if req.Model == `` {
+		req.Model = from(ctx).model
+	}
+	req.Stream = true
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+	if len(req.Tools) > 0 {
+		return nil, &chat.InvalidRequestError{Reason: `ChatReader cannot handle tool calls; use chat.StreamHook with Chat`}
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	pr, pw := io.Pipe()
+	go func() {
+		var splicer runeSplicer
+		err := from(ctx).Stream(ctx, func(msg json.RawMessage) error {
+			var rsp struct {
+				Message struct {
+					Content rawText `json:"content"`
+				} `json:"message"`
+			}
+			err := json.Unmarshal(msg, &rsp)
+			if err != nil {
+				return err
+			}
+			_, err = pw.Write(splicer.splice(rsp.Message.Content))
+			return err
+		}, `POST`, req, `/api/chat`)
+		if err == nil {
+			_, err = pw.Write(splicer.flush())
+		}
+		pw.CloseWithError(err)
+	}()
+	return &chatReader{pr, cancel}, nil
+}
+
+type chatReader struct {
+	*io.PipeReader
+	cancel context.CancelFunc
+}
+
+func (r *chatReader) Close() error {
+	r.cancel()
+	return r.PipeReader.Close()
+}
+
+// doChat sends the chat request, handling any tool calls.  Each tool call from the model, and the tool messages in
+// response to them, are appended to the request messages, so the request contains the full history of the chat except
+// the final response.
+func doChat(ctx context.Context, req *chat.Request) (*chat.Response, error) {
+	if req.Model == `` {
+		req.Model = from(ctx).model
+	}
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+	if err := checkCapabilities(ctx, req.Model, req.RequiredCapabilities()); err != nil {
+		return nil, err
+	}
+	if budget := req.Deadline(); budget > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeoutCause(ctx, budget, chat.ErrDeadlineExceeded)
+		defer cancel()
+	}
 	toolkit := req.Toolkit()
+	var last *chat.Response
 	for {
-		var rsp chat.Response
-		err := from(ctx).Do(ctx, &rsp, `POST`, req, `/api/chat`)
+		if req.AutoNumCtx() {
+			if err := autoNumCtx(ctx, req); err != nil {
+				return nil, err
+			}
+		}
+		rsp, err := sendChat(ctx, req)
+		if err != nil && context.Cause(ctx) == chat.ErrDeadlineExceeded {
+			return last, chat.ErrDeadlineExceeded
+		}
 		if err != nil {
 			return nil, err
 		}
-		if toolkit == nil || len(rsp.Message.ToolCalls) == 0 {
-			return &rsp, nil
+		last = &rsp
+		if toolkit == nil || len(rsp.Message.ToolCalls) == 0 || req.StopWhen(&rsp) {
+			return &rsp, req.CheckContent(&rsp)
 		}
+		n := len(req.Messages)
+		req.Messages = append(req.Messages, rsp.Message)
 		for _, call := range rsp.Message.ToolCalls {
 			msg, err := toolkit.Call(ctx, call)
-			if err != nil {
+			if errors.Is(err, chat.Stop{}) {
+				// The response is returned as the final message, so its tool calls and their results are dropped.
+				clear(req.Messages[n:])
+				req.Messages = req.Messages[:n]
+				return &rsp, nil
+			}
+			if err != nil && req.ToolErrorsFatal() {
 				return &rsp, err
 			}
 			req.Messages = append(req.Messages, msg)
@@ -58,14 +220,344 @@ func Chat(ctx context.Context, options ...chat.Option) (*chat.Response, error) {
 // or to find relevant inputs.
 func Embed(ctx context.Context, options ...embed.Option) (*embed.Response, error) {
 	req := newRequest[embed.Request](options...)
-	var rsp embed.Response
-	err := from(ctx).Do(ctx, &rsp, `POST`, req, `/api/embed`)
+	return doEmbed(ctx, req)
+}
+
+// doEmbed sends an embed request, falling back to the legacy /api/embeddings endpoint if /api/embed is not found and the
+// embed.AllowLegacyFallback option was used.  A 404 for a missing model is returned as is.
+func doEmbed(ctx context.Context, req *embed.Request) (*embed.Response, error) {
+	if req.Model == `` {
+		req.Model = from(ctx).model
+	}
+	// encoding/json reuses the capacity of slices it decodes into, including the embeddings within them.
+	rsp := embed.Response{Embeddings: req.Into()}
+	var err error
+	if fn := req.OnVector(); fn != nil {
+		err = from(ctx).exchange(ctx, `POST`, req, `/api/embed`, func(dec Decoder) error {
+			return decodeEmbeddings(dec, &rsp, fn)
+		})
+	} else {
+		err = from(ctx).Do(ctx, &rsp, `POST`, req, `/api/embed`)
+	}
+	var oerr *Error
+	if errors.As(err, &oerr) && oerr.StatusCode == http.StatusNotFound && !oerr.IsModelNotFound() &&
+		req.AllowLegacyFallback() {
+		rsp, err := embedLegacy(ctx, req)
+		if err == nil && req.OnVector() != nil {
+			for i, vec := range rsp.Embeddings {
+				req.OnVector()(i, vec)
+			}
+			rsp.Embeddings = nil
+		}
+		return rsp, err
+	}
 	if err != nil {
 		return nil, err
 	}
 	return &rsp, nil
 }
 
+// EmbedLegacy uses the legacy /api/embeddings endpoint, which only accepts a single prompt, to return a vector describing
+// the prompt.  This is only useful for older Ollama servers that do not support /api/embed.
+func EmbedLegacy(ctx context.Context, model, prompt string) ([]float32, error) {
+	if model == `` {
+		model = from(ctx).model
+	}
+	var rsp embed.LegacyResponse
+	err := from(ctx).Do(ctx, &rsp, `POST`, &embed.LegacyRequest{Model: model, Prompt: prompt}, `/api/embeddings`)
+	if err != nil {
+		return nil, err
+	}
+	return rsp.Embedding, nil
+}
+
+// embedLegacy emulates an embed request using one legacy request per input.
+func embedLegacy(ctx context.Context, req *embed.Request) (*embed.Response, error) {
+	ret := embed.Response{Model: req.Model, Embeddings: make([][]float32, 0, len(req.Input))}
+	for _, input := range req.Input {
+		var rsp embed.LegacyResponse
+		err := from(ctx).Do(ctx, &rsp, `POST`, &embed.LegacyRequest{
+			Model:   req.Model,
+			Prompt:  input,
+			Options: req.Options,
+		}, `/api/embeddings`)
+		if err != nil {
+			return nil, err
+		}
+		ret.Embeddings = append(ret.Embeddings, rsp.Embedding)
+	}
+	return &ret, nil
+}
+
+// EmbedAll is like Embed, but splits the inputs into batches using the size specified by the embed.BatchSize option,
+// sending one request per batch.  The embeddings are returned in the same order as the inputs, and the durations and counts
+// of each response are summed.
+func EmbedAll(ctx context.Context, options ...embed.Option) (*embed.Response, error) {
+	req := newRequest[embed.Request](options...)
+	inputs, n := req.Input, req.BatchSize()
+	if n <= 0 {
+		n = len(inputs)
+	}
+	ret := embed.Response{Model: req.Model, Embeddings: make([][]float32, 0, len(inputs))}
+	into := req.Into()
+	if into != nil {
+		ret.Embeddings = into[:0]
+	}
+	for offset := 0; len(inputs) > 0; {
+		batch := *req
+		batch.Input = inputs[:min(n, len(inputs))]
+		inputs = inputs[len(batch.Input):]
+		if fn := req.OnVector(); fn != nil {
+			// Each batch reports indexes relative to the first input of the batch.
+			base := offset
+			embed.OnVector(func(i int, vec []float32) { fn(base+i, vec) })(&batch)
+		}
+		offset += len(batch.Input)
+		if done := len(ret.Embeddings); done < len(into) {
+			// Each batch decodes into the part of the buffer after the previous batches.
+			end := min(done+len(batch.Input), len(into))
+			embed.Into(into[done:end:end])(&batch)
+		} else {
+			embed.Into(nil)(&batch)
+		}
+		rsp, err := doEmbed(ctx, &batch)
+		if err != nil {
+			return nil, err
+		}
+		ret.Model = rsp.Model
+		ret.Embeddings = append(ret.Embeddings, rsp.Embeddings...)
+		ret.TotalDuration += rsp.TotalDuration
+		ret.LoadDuration += rsp.LoadDuration
+		ret.PromptEvalCount += rsp.PromptEvalCount
+	}
+	return &ret, nil
+}
+
+// Ping checks that the Ollama server is reachable by requesting its version, returning nil if it responds.  Use a
+// context with a short deadline for readiness probes.  If the server responds with an error status, the returned error
+// wraps an *Error; otherwise, it wraps the error from the connection, such as a context.DeadlineExceeded.
+func Ping(ctx context.Context) error {
+	var rsp struct {
+		Version string `json:"version"`
+	}
+	ct := from(ctx)
+	err := ct.Do(ctx, &rsp, `GET`, nil, `/api/version`)
+	if err != nil {
+		return fmt.Errorf(`%w while pinging %v`, err, hostURL(ct.ollamaHost))
+	}
+	return nil
+}
+
+// LoadModel loads a model into memory without generating anything, and keeps it loaded for keepAlive, returning how
+// long it took to load.  A negative keepAlive keeps the model loaded indefinitely, and zero unloads it.  If model is
+// empty, the model from the ollama.Model client option is used.
+func LoadModel(ctx context.Context, model string, keepAlive time.Duration) (time.Duration, error) {
+	if model == `` {
+		model = from(ctx).model
+	}
+	if model == `` {
+		return 0, fmt.Errorf(`a model is required; pass one to LoadModel or use the ollama.Model client option`)
+	}
+	req := struct {
+		Model     string `json:"model"`
+		KeepAlive any    `json:"keep_alive"`
+	}{Model: model, KeepAlive: keepAlive.String()}
+	switch {
+	case keepAlive < 0:
+		req.KeepAlive = -1 // Ollama treats any negative number as forever, but only as a number.
+	case keepAlive == 0:
+		req.KeepAlive = 0
+	}
+	var rsp generate.Response
+	err := from(ctx).Do(ctx, &rsp, `POST`, &req, `/api/generate`)
+	if err != nil {
+		return 0, err
+	}
+	return rsp.LoadDuration, nil
+}
+
+// Generate completes a prompt.  If the generate.Stream option is used, each chunk of the response is passed to its
+// function as it is generated, and the returned response combines the chunks, with the context and statistics of the
+// final chunk.  A rune split between chunks by Ollama is held back until it is complete.
+func Generate(ctx context.Context, options ...generate.Option) (*generate.Response, error) {
+	req := newRequest[generate.Request](options...)
+	if req.Model == `` {
+		req.Model = from(ctx).model
+	}
+	stream := req.StreamFunc()
+	if stream == nil {
+		var rsp generate.Response
+		err := from(ctx).Do(ctx, &rsp, `POST`, req, `/api/generate`)
+		if err != nil {
+			return nil, err
+		}
+		return &rsp, nil
+	}
+	req.Stream = true
+	var text strings.Builder
+	var ret generate.Response
+	var splicer runeSplicer
+	err := from(ctx).Stream(ctx, func(msg json.RawMessage) error {
+		var rsp generate.Response
+		err := json.Unmarshal(msg, &rsp)
+		if err != nil {
+			return err
+		}
+		var raw struct {
+			Response rawText `json:"response"`
+		}
+		err = json.Unmarshal(msg, &raw)
+		if err != nil {
+			return err
+		}
+		chunk := splicer.splice(raw.Response)
+		if rsp.Done {
+			chunk = append(chunk, splicer.flush()...)
+		}
+		rsp.Response = string(chunk)
+		text.WriteString(rsp.Response)
+		ret = rsp
+		return stream(&rsp)
+	}, `POST`, req, `/api/generate`)
+	if err != nil {
+		return nil, err
+	}
+	ret.Response = text.String()
+	return &ret, nil
+}
+
+// CreateModel creates a model named name, either from a Modelfile or from a base model with overrides, such as a new
+// system prompt.  Status updates are passed to the function from the create.Progress option, if any.
+func CreateModel(ctx context.Context, name string, options ...create.Option) error {
+	req := newRequest[create.Request](options...)
+	req.Model, req.Stream = name, true
+	defer forgetModel(ctx, name)
+	progress := req.Progress()
+	return from(ctx).Stream(ctx, func(msg json.RawMessage) error {
+		if progress == nil {
+			return nil
+		}
+		var rsp create.Response
+		err := json.Unmarshal(msg, &rsp)
+		if err != nil {
+			return err
+		}
+		progress(&rsp)
+		return nil
+	}, `POST`, req, `/api/create`)
+}
+
+// PullModel pulls a model from a registry, such as ollama.com.  Status updates are passed to the function from the
+// pull.Progress option, and the start and end of each layer are passed to the function from the pull.OnLayer option.
+// It returns an error if Ollama does not report that the digests of the model were verified successfully.
+//
+// Ollama keeps partially downloaded layers, so if a pull fails, calling PullModel again resumes it and skips the layers
+// that are already complete.
+func PullModel(ctx context.Context, name string, options ...pull.Option) error {
+	req := newRequest[pull.Request](options...)
+	req.Model, req.Stream = name, true
+	defer forgetModel(ctx, name)
+	progress, onLayer := req.Progress(), req.OnLayer()
+	layers := make(map[string]bool) // digest -> done
+	verified, succeeded := false, false
+	err := from(ctx).Stream(ctx, func(msg json.RawMessage) error {
+		var rsp pull.Response
+		err := json.Unmarshal(msg, &rsp)
+		if err != nil {
+			return err
+		}
+		switch rsp.Status {
+		case pull.StatusVerifying:
+			verified = true
+		case pull.StatusSuccess:
+			succeeded = true
+		}
+		if rsp.Digest != `` && rsp.Total > 0 {
+			done, seen := layers[rsp.Digest]
+			if !seen {
+				layers[rsp.Digest] = false
+				if onLayer != nil {
+					onLayer(rsp.Digest, false)
+				}
+			}
+			if !done && rsp.Completed >= rsp.Total {
+				layers[rsp.Digest] = true
+				if onLayer != nil {
+					onLayer(rsp.Digest, true)
+				}
+			}
+		}
+		if progress != nil {
+			progress(&rsp)
+		}
+		return nil
+	}, `POST`, req, `/api/pull`)
+	switch {
+	case err != nil:
+		return err
+	case !succeeded:
+		return fmt.Errorf(`pull of %q ended before it succeeded`, name)
+	case !verified && len(layers) > 0:
+		return fmt.Errorf(`pull of %q succeeded without verifying its digests`, name)
+	}
+	return nil
+}
+
+// PushBlob uploads the content of the reader as a blob, returning its digest, which can be used to create a model from
+// local files, such as a GGUF file.  If the reader cannot seek, it is copied to a temporary file while computing the
+// digest, since Ollama needs the digest before the upload.  A reader that can seek is uploaded from its current
+// offset, and is not closed, even if it is an io.Closer like *os.File.
+func PushBlob(ctx context.Context, r io.Reader) (digest string, err error) {
+	hash := sha256.New()
+	var start int64
+	rs, ok := r.(io.ReadSeeker)
+	if ok {
+		start, err = rs.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return ``, err
+		}
+		_, err = io.Copy(hash, rs)
+	} else {
+		var tmp *os.File
+		tmp, err = os.CreateTemp(``, `ollama-blob-*`)
+		if err != nil {
+			return ``, err
+		}
+		defer os.Remove(tmp.Name())
+		defer tmp.Close()
+		_, err = io.Copy(io.MultiWriter(hash, tmp), r)
+		rs = tmp
+	}
+	if err != nil {
+		return ``, err
+	}
+	_, err = rs.Seek(start, io.SeekStart)
+	if err != nil {
+		return ``, err
+	}
+	digest = `sha256:` + hex.EncodeToString(hash.Sum(nil))
+	// net/http closes a request body that is an io.Closer, which is not ours to close.
+	err = from(ctx).Do(ctx, nil, `POST`, io.NopCloser(rs), `/api/blobs/`+digest)
+	if err != nil {
+		return ``, err
+	}
+	return digest, nil
+}
+
+// HasBlob returns true if Ollama has a blob with the provided digest, such as one returned by PushBlob.
+func HasBlob(ctx context.Context, digest string) (bool, error) {
+	err := from(ctx).Do(ctx, nil, `HEAD`, nil, `/api/blobs/`+digest)
+	var oerr *Error
+	switch {
+	case err == nil:
+		return true, nil
+	case errors.As(err, &oerr) && oerr.StatusCode == http.StatusNotFound:
+		return false, nil
+	default:
+		return false, err
+	}
+}
+
 func newRequest[
 	Req any,
 	Option ~func(*Req),
@@ -84,15 +576,36 @@ var Default = defaultClient
 func New(options ...Option) *Client { return defaultClient.Apply(options...) }
 
 // TraceZerolog adds a zerolog trace using the provided logger that traces requests and responses.
-func TraceZerolog(logger zerolog.Logger) Option {
+func TraceZerolog(logger zerolog.Logger) Option { return TraceZerologRedacted(logger) }
+
+// TraceZerologRedacted is like TraceZerolog, but passes the content of each request and response through the redactors,
+// in order, before it is logged.  This can be used to keep personal information or secrets out of logs, using redactors
+// like RedactFields and TruncateStrings.
+func TraceZerologRedacted(logger zerolog.Logger, redactors ...func(json.RawMessage) json.RawMessage) Option {
+	redact := func(msg json.RawMessage) json.RawMessage {
+		for _, redactor := range redactors {
+			msg = redactor(msg)
+		}
+		return msg
+	}
 	return func(ct *Client) {
 		ct.requestHooks = append(ct.requestHooks, func(req *http.Request) error {
 			logger.Trace().Func(func(e *zerolog.Event) {
 				e.Str(`method`, req.Method).Stringer(`url`, req.URL)
-				body := stealBody(&req.Body)
+				if id := requestID(req.Context()); id != `` {
+					e.Str(`request_id`, id)
+				}
+				if !isJSON(req.Header) {
+					return // such as a blob, which could be huge.
+				}
+				body, err := stealBody(&req.Body)
+				if err != nil {
+					e.AnErr(`read_error`, err)
+					return
+				}
 				var msg json.RawMessage
 				if err := json.Unmarshal(body, &msg); err == nil {
-					e.RawJSON(`request`, msg)
+					e.RawJSON(`request`, redact(msg))
 				}
 			}).Msg(`sending Ollama request`)
 			return nil
@@ -101,10 +614,21 @@ func TraceZerolog(logger zerolog.Logger) Option {
 			req := rsp.Request
 			logger.Trace().Func(func(e *zerolog.Event) {
 				e.Str(`method`, req.Method).Stringer(`url`, req.URL).Int(`status`, rsp.StatusCode)
-				body := stealBody(&req.Body)
+				if id := requestID(req.Context()); id != `` {
+					e.Str(`request_id`, id)
+				}
+				if !isJSON(rsp.Header) {
+					// Streamed responses are NDJSON, and reading them here would delay every chunk until the end.
+					return
+				}
+				body, err := stealBody(&rsp.Body)
+				if err != nil {
+					e.AnErr(`read_error`, err)
+					return
+				}
 				var msg json.RawMessage
 				if err := json.Unmarshal(body, &msg); err == nil {
-					e.RawJSON(`response`, msg)
+					e.RawJSON(`response`, redact(msg))
 				}
 			}).Msg(`received Ollama response`)
 			return nil
@@ -112,12 +636,78 @@ func TraceZerolog(logger zerolog.Logger) Option {
 	}
 }
 
-func stealBody(rr *io.ReadCloser) []byte {
+// RedactFields returns a redactor for TraceZerologRedacted that replaces the value of any property with one of the
+// provided names, at any depth, with "[REDACTED]".  For example, RedactFields("content") hides the content of messages.
+func RedactFields(names ...string) func(json.RawMessage) json.RawMessage {
+	return redactor(func(name string, value any) any {
+		if slices.Contains(names, name) {
+			return `[REDACTED]`
+		}
+		return value
+	})
+}
+
+// TruncateStrings returns a redactor for TraceZerologRedacted that truncates any string longer than n runes, which keeps
+// long messages and images from overwhelming logs.  A negative n is treated as zero.
+func TruncateStrings(n int) func(json.RawMessage) json.RawMessage {
+	n = max(n, 0)
+	return redactor(func(name string, value any) any {
+		str, ok := value.(string)
+		if !ok || utf8.RuneCountInString(str) <= n {
+			return value
+		}
+		return string([]rune(str)[:n]) + `...`
+	})
+}
+
+// redactor returns a function that parses JSON and applies the provided function to each property and element, at any
+// depth, before formatting it again.  Elements of arrays are passed the name of the array.  Objects and arrays are passed
+// to the function before their contents.
+func redactor(fn func(name string, value any) any) func(json.RawMessage) json.RawMessage {
+	var walk func(name string, value any) any
+	walk = func(name string, value any) any {
+		switch value := fn(name, value).(type) {
+		case map[string]any:
+			for k, v := range value {
+				value[k] = walk(k, v)
+			}
+			return value
+		case []any:
+			for i, v := range value {
+				value[i] = walk(name, v)
+			}
+			return value
+		default:
+			return value
+		}
+	}
+	return func(msg json.RawMessage) json.RawMessage {
+		var value any
+		if json.Unmarshal(msg, &value) != nil {
+			return msg
+		}
+		ret, err := json.Marshal(walk(``, value))
+		if err != nil {
+			return msg
+		}
+		return ret
+	}
+}
+
+// isJSON returns true if the content type of the headers is JSON, not NDJSON or anything else.
+func isJSON(header http.Header) bool {
+	contentType, _, _ := strings.Cut(header.Get(`Content-Type`), `;`)
+	return strings.TrimSpace(contentType) == `application/json`
+}
+
+// stealBody reads and closes the body, replacing it with a copy so it can still be read.  If reading the body fails,
+// the copy returns the content that was read, followed by the error, so the reader of the body still sees the failure.
+func stealBody(rr *io.ReadCloser) ([]byte, error) {
 	var body []byte
 	var err error
 	switch r := (*rr).(type) {
 	case nil:
-		return nil
+		return nil, nil
 	case interface {
 		io.ReadCloser
 		Bytes() []byte
@@ -126,14 +716,12 @@ func stealBody(rr *io.ReadCloser) []byte {
 		_ = r.Close()
 	default:
 		body, err = io.ReadAll(r)
+		_ = r.Close()
 	}
-	if err != nil {
-		panic(err) // TODO: shim in a reader that returns an error.
-	}
-	var bt bodyThief
+	bt := bodyThief{err: err}
 	bt.Write(body)
 	*rr = &bt
-	return body
+	return body, err
 }
 
 type bodyThief struct {
@@ -162,22 +750,156 @@ func ResponseHook(hook func(*http.Response) error) Option {
 	return func(ct *Client) { ct.responseHooks = append(ct.responseHooks, hook) }
 }
 
-// Host specifies the base URL of the Ollama server.  This may be either a URL without a trailing "/" or a TCP/IP address,
-// in which case, HTTP will be used.  The default host is `http://localhost:11434` but if OLLAMA_HOST is present in the
-// environment, it will be used instead.
+// RoundTripHook adds a hook that wraps the exchange of a request for a response, after request hooks are applied and
+// before response hooks are applied.  Unlike request and response hooks, a round trip hook sees both the request and its
+// response, so it can measure latency or correlate the two.  The first hook added is the outermost.
+func RoundTripHook(hook func(next RoundTrip) RoundTrip) Option {
+	return func(ct *Client) { ct.roundTripHooks = append(ct.roundTripHooks, hook) }
+}
+
+// RoundTrip exchanges an HTTP request for a response.
+type RoundTrip func(*http.Request) (*http.Response, error)
+
+// RateLimit throttles requests from the client, waiting for the limiter before each request is sent, or until the
+// context of the request is done.  A *rate.Limiter from golang.org/x/time/rate, such as rate.NewLimiter(r, burst), is
+// a suitable limiter.
+//
+// Clients derived from this one with Apply or With share the same limiter, so they are throttled together.
+func RateLimit(limiter interface{ Wait(context.Context) error }) Option {
+	return RoundTripHook(func(next RoundTrip) RoundTrip {
+		return func(req *http.Request) (*http.Response, error) {
+			err := limiter.Wait(req.Context())
+			if err != nil {
+				return nil, err
+			}
+			return next(req)
+		}
+	})
+}
+
+// Host specifies the base URL of the Ollama server.  This may be either a URL or a TCP/IP address, in which case, HTTP
+// will be used.  Like the Ollama CLI, a missing host means localhost and a missing port means 11434, so ":11434",
+// "localhost" and "http://localhost:11434" are equivalent.  The default host is `http://localhost:11434` but if
+// OLLAMA_HOST is present in the environment, it will be used instead.
 func Host(host string) Option {
 	return func(ct *Client) { ct.ollamaHost = host }
 }
 
+// Model specifies the default model for chat and embed requests that do not specify their own model.
+func Model(name string) Option {
+	return func(ct *Client) { ct.model = name }
+}
+
+// UserAgent replaces the User-Agent header sent with each request, which defaults to "ollama-client/" followed by
+// Version.
+func UserAgent(userAgent string) Option {
+	return func(ct *Client) { ct.userAgent = userAgent }
+}
+
+func (ct *Client) userAgentOrDefault() string {
+	if ct.userAgent == `` {
+		return `ollama-client/` + Version
+	}
+	return ct.userAgent
+}
+
+// Version is the version of this module, from the build information of the program, or "devel" if the program was
+// not built with module information, such as in tests.
+var Version = func() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return `devel`
+	}
+	for _, dep := range info.Deps {
+		if dep.Path == modulePath {
+			return strings.TrimPrefix(dep.Version, `v`)
+		}
+	}
+	if info.Main.Path == modulePath && info.Main.Version != `` && info.Main.Version != `(devel)` {
+		return strings.TrimPrefix(info.Main.Version, `v`)
+	}
+	return `devel`
+}()
+
+const modulePath = `github.com/swdunlop/ollama-client`
+
+// WithCodec replaces encoding/json as the codec used to encode requests and decode responses, such as with a faster
+// JSON library for decoding large embedding responses.  The codec must support json.RawMessage and the json struct
+// tags, since the request and response types rely on them.
+func WithCodec(codec Codec) Option {
+	return func(ct *Client) { ct.jsonCodec = codec }
+}
+
+// A Codec encodes requests to JSON and decodes responses from JSON for a client, see WithCodec.
+type Codec interface {
+	Marshal(v any) ([]byte, error)
+	NewDecoder(r io.Reader) Decoder
+}
+
+// A Decoder decodes a sequence of JSON values, like json.Decoder.
+type Decoder interface {
+	Decode(v any) error
+}
+
+// stdCodec is the default codec, using encoding/json.
+type stdCodec struct{}
+
+func (stdCodec) Marshal(v any) ([]byte, error)  { return json.Marshal(v) }
+func (stdCodec) NewDecoder(r io.Reader) Decoder { return json.NewDecoder(r) }
+
+// codec returns the codec provided by WithCodec, or encoding/json.
+func (ct *Client) codec() Codec {
+	if ct.jsonCodec == nil {
+		return stdCodec{}
+	}
+	return ct.jsonCodec
+}
+
+// Timeout limits how long each request may take, including reading the response; if the context of a request has an
+// earlier deadline, that deadline is used instead.  For streamed requests, this limits the duration of the whole stream,
+// not the time between chunks, so it should be generous for long responses.
+func Timeout(d time.Duration) Option {
+	return func(ct *Client) { ct.timeout = d }
+}
+
+// UseNumber decodes numbers in responses as json.Number instead of float64 when the destination is an interface, such
+// as a map[string]any passed to Do, which avoids losing precision in large integers.  This only affects codecs whose
+// decoders have a UseNumber method, like encoding/json.
+func UseNumber() Option {
+	return func(ct *Client) { ct.useNumber = true }
+}
+
 type Option func(*Client)
 
+// A Client sends requests to an Ollama server.  Clients are immutable once constructed by New, Apply or With, so they
+// are safe for concurrent use by multiple goroutines.  Options that share state between requests, like RateLimit and
+// CircuitBreaker, synchronize it themselves, and hooks must be safe for concurrent use if the client is.
 type Client struct {
 	// ollamaHost is the base URL of the Ollama server.  This should not have a trailing "/".  An address can be used, or
 	// a URL.
 	ollamaHost string
 
-	requestHooks  []func(*http.Request) error
-	responseHooks []func(*http.Response) error
+	// model is the default model for requests that do not specify one.
+	model string
+
+	// useNumber is true if responses should be decoded with json.Decoder.UseNumber.
+	useNumber bool
+
+	// timeout limits the duration of each request, if positive.
+	timeout time.Duration
+
+	// jsonCodec replaces encoding/json, if not nil.
+	jsonCodec Codec
+
+	// userAgent replaces the default User-Agent header, if not empty.
+	userAgent string
+
+	// cacheTTL limits how long model information is cached, if positive.
+	cacheTTL time.Duration
+
+	requestHooks   []func(*http.Request) error
+	responseHooks  []func(*http.Response) error
+	roundTripHooks []func(RoundTrip) RoundTrip
 }
 
 var defaultClient = func() (ct Client) {
@@ -187,10 +909,15 @@ var defaultClient = func() (ct Client) {
 	return
 }()
 
-// Apply returns a client improved with new options.
+// Apply returns a client improved with new options.  The original client is not changed, so it is safe to derive
+// clients from a client that is in use.
 func (ct *Client) Apply(options ...Option) *Client {
 	cp := *ct
-	// ensure any slices are cloned so we do not share capacities
+	// ensure any slices are cloned so we do not share capacities; otherwise, two clients derived from the same client
+	// could append their hooks to the same array.
+	cp.requestHooks = slices.Clone(cp.requestHooks)
+	cp.responseHooks = slices.Clone(cp.responseHooks)
+	cp.roundTripHooks = slices.Clone(cp.roundTripHooks)
 	for _, option := range options {
 		option(&cp)
 	}
@@ -199,37 +926,104 @@ func (ct *Client) Apply(options ...Option) *Client {
 
 // Do exchanges a Request for a Response or an error.
 func (ct *Client) Do(ctx context.Context, rsp any, method string, req any, api string) error {
-	url := ct.ollamaHost
-	if strings.Contains(url, `://`) {
-		url = strings.TrimSuffix(url, `/`)
-	} else {
-		url = `http://` + url
+	return ct.exchange(ctx, method, req, api, func(dec Decoder) error {
+		if rsp == nil {
+			return nil
+		}
+		return dec.Decode(rsp)
+	})
+}
+
+// Stream exchanges a Request for a streamed response, where each JSON object in the response is passed to the function in
+// turn.  If the function returns an error, the exchange is aborted and the error is returned.  If an object has an
+// "error" property, as Ollama sends when a streamed request fails after it starts, that error is returned instead.
+func (ct *Client) Stream(
+	ctx context.Context, fn func(json.RawMessage) error, method string, req any, api string,
+) error {
+	return ct.exchange(ctx, method, req, api, func(dec Decoder) error {
+		for {
+			var msg json.RawMessage
+			err := dec.Decode(&msg)
+			switch {
+			case err == io.EOF:
+				return nil
+			case err != nil:
+				return err
+			}
+			var failure struct {
+				Error string `json:"error"`
+			}
+			if json.Unmarshal(msg, &failure) == nil && failure.Error != `` {
+				return errors.New(failure.Error)
+			}
+			err = fn(msg)
+			if err != nil {
+				return err
+			}
+		}
+	})
+}
+
+// exchange sends a request to Ollama and uses the decode function to process the response content.
+func (ct *Client) exchange(
+	ctx context.Context, method string, req any, api string, decode func(Decoder) error,
+) error {
+	url := hostURL(ct.ollamaHost) + api
+	ctx = context.WithValue(ctx, ctxRequest{}, req)
+	if ct.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, ct.timeout)
+		defer cancel()
 	}
-	url += api
 
 	var hreq *http.Request
-	switch method {
-	case `POST`, `PUT`, `PATCH`:
-		requestJSON, err := json.Marshal(req)
+	switch req := req.(type) {
+	case nil:
+		var err error
+		hreq, err = http.NewRequestWithContext(ctx, method, url, nil)
 		if err != nil {
 			return err
 		}
-		// json.NewEncoder(os.Stdout).Encode(req)
-		hreq, err = http.NewRequestWithContext(ctx, method, url, bytes.NewReader(requestJSON))
+	case *RawBody:
+		var err error
+		hreq, err = http.NewRequestWithContext(ctx, method, url, req.Body)
 		if err != nil {
 			return err
 		}
-		hreq.Header.Set(`Content-Length`, strconv.Itoa(len(requestJSON)))
-		hreq.Header.Set(`Content-Type`, `application/json`)
-	default:
-		if req != nil {
-			return fmt.Errorf(`unexpected %#T content for method %q`, req, method)
+		if req.ContentType != `` {
+			hreq.Header.Set(`Content-Type`, req.ContentType)
 		}
+	case io.Reader:
+		// Readers are sent verbatim, such as when uploading a blob.
 		var err error
-		hreq, err = http.NewRequestWithContext(ctx, method, url, nil)
+		hreq, err = http.NewRequestWithContext(ctx, method, url, req)
 		if err != nil {
 			return err
 		}
+		hreq.Header.Set(`Content-Type`, `application/octet-stream`)
+	default:
+		// Ollama expects JSON content for more than just POST, PUT and PATCH -- /api/delete uses DELETE with a JSON body.
+		requestJSON, err := ct.codec().Marshal(req)
+		if err != nil {
+			return err
+		}
+		// NewRequestWithContext sets the content length from the bytes.Reader, so a hook that replaces the body must
+		// also update hreq.ContentLength, or set it to -1 for a chunked body.
+		hreq, err = http.NewRequestWithContext(ctx, method, url, bytes.NewReader(requestJSON))
+		if err != nil {
+			return err
+		}
+		hreq.Header.Set(`Content-Type`, `application/json`)
+	}
+
+	hreq.Header.Set(`User-Agent`, ct.userAgentOrDefault())
+	if id := requestID(ctx); id != `` {
+		hreq.Header.Set(`X-Request-ID`, id)
+	}
+	if header, ok := ctx.Value(ctxHeader{}).(http.Header); ok {
+		for key, values := range header {
+			hreq.Header[key] = slices.Clone(values) // so hooks that add to the header do not alter the context.
+		}
 	}
 
 	for _, hook := range ct.requestHooks {
@@ -239,17 +1033,26 @@ func (ct *Client) Do(ctx context.Context, rsp any, method string, req any, api s
 		}
 	}
 
-	hrsp, err := http.DefaultClient.Do(hreq)
+	roundTrip := RoundTrip(http.DefaultClient.Do)
+	for i := len(ct.roundTripHooks) - 1; i >= 0; i-- {
+		roundTrip = ct.roundTripHooks[i](roundTrip)
+	}
+	hrsp, err := roundTrip(hreq)
 	if err != nil {
 		return err
 	}
+	// The body must be drained and closed on every path, including hook failures, or the connection cannot be reused.
+	body := hrsp.Body
+	defer func() {
+		_, _ = io.Copy(io.Discard, body)
+		_ = body.Close()
+	}()
 	for i := len(ct.responseHooks) - 1; i >= 0; i-- {
 		err = ct.responseHooks[i](hrsp)
 		if err != nil {
 			return err
 		}
 	}
-	defer hrsp.Body.Close()
 
 	if hrsp.StatusCode < 200 || hrsp.StatusCode > 299 {
 		content, _ := io.ReadAll(hrsp.Body)
@@ -259,35 +1062,128 @@ func (ct *Client) Do(ctx context.Context, rsp any, method string, req any, api s
 			Status:     hrsp.Status,
 			Header:     hrsp.Header,
 			Content:    content,
+			RequestID:  requestID(ctx),
 		}
 	}
 
-	if rsp != nil {
-		err = json.NewDecoder(hrsp.Body).Decode(rsp)
+	// Once the headers arrive, a stalled server can leave the decoder blocked on the body; closing the body when the
+	// context is done ensures cancellation still aborts the read.
+	stop := context.AfterFunc(ctx, func() { hrsp.Body.Close() })
+	defer stop()
+	dec := ct.codec().NewDecoder(hrsp.Body)
+	if un, ok := dec.(interface{ UseNumber() }); ok && ct.useNumber {
+		un.UseNumber()
+	}
+	err = decode(dec)
+	if err != nil && ctx.Err() != nil {
+		err = ctx.Err()
 	}
 	return err
 }
 
+// RawBody is a request body that Client.Do sends verbatim with the specified content type, instead of encoding it as JSON.
+// This is useful for content that is not JSON, such as form data; a plain io.Reader is sent as application/octet-stream.
+type RawBody struct {
+	ContentType string
+	Body        io.Reader
+}
+
 type Error struct {
 	URL        string
 	StatusCode int
 	Status     string
 	Header     http.Header
 	Content    []byte
+
+	// RequestID is the id of the request from WithRequestID, if any.
+	RequestID string
 }
 
 func (err *Error) Error() string { return err.Status }
 
-// hostURL tries to detect if the host is a URL or a network address and return an actual URL; it will return
-// an empty string if the host does not match either.
+// Message returns the error message from Ollama in the content of the response, if any.
+func (err *Error) Message() string {
+	var content struct {
+		Error string `json:"error"`
+	}
+	_ = json.Unmarshal(err.Content, &content)
+	return content.Error
+}
+
+// IsModelNotFound returns true if Ollama does not have the requested model, which will not be fixed by retrying the
+// request; the model must be pulled first.
+func (err *Error) IsModelNotFound() bool {
+	// A 404 without a message mentioning a model is more likely an endpoint this version of Ollama does not support.
+	msg := err.Message()
+	return strings.Contains(msg, `model`) && (err.StatusCode == http.StatusNotFound || strings.Contains(msg, `not found`))
+}
+
+// IsModelLoading returns true if Ollama is busy, such as while loading a model, and the request should be retried later.
+func (err *Error) IsModelLoading() bool {
+	return err.StatusCode == http.StatusServiceUnavailable || strings.Contains(err.Message(), `server busy`)
+}
+
+// IsRateLimited returns true if the request was rejected because too many requests have been sent, and the request
+// should be retried later.
+func (err *Error) IsRateLimited() bool { return err.StatusCode == http.StatusTooManyRequests }
+
+// Is lets errors.Is match an Error with ErrModelNotFound, ErrModelLoading and ErrRateLimited.
+func (err *Error) Is(target error) bool {
+	switch target {
+	case ErrModelNotFound:
+		return err.IsModelNotFound()
+	case ErrModelLoading:
+		return err.IsModelLoading()
+	case ErrRateLimited:
+		return err.IsRateLimited()
+	}
+	return false
+}
+
+var (
+	// ErrModelNotFound matches errors where Ollama does not have the requested model, see Error.IsModelNotFound.
+	ErrModelNotFound = errors.New(`model not found`)
+
+	// ErrModelLoading matches errors where Ollama is busy loading a model, see Error.IsModelLoading.
+	ErrModelLoading = errors.New(`model loading`)
+
+	// ErrRateLimited matches errors where Ollama has received too many requests, see Error.IsRateLimited.
+	ErrRateLimited = errors.New(`rate limited`)
+)
+
+// hostURL tries to detect if the host is a URL or a network address and return an actual URL without a trailing "/",
+// following the same rules as the Ollama CLI for OLLAMA_HOST:
+//
+//   - A host without a scheme uses HTTP, so "example.com:8080" becomes "http://example.com:8080".
+//   - A missing host is localhost, so ":8080" becomes "http://127.0.0.1:8080".
+//   - A missing port is 11434 without a scheme, or the default port for HTTP and HTTPS with one.
+//   - IPv6 literals may be bracketed or bare, so "::1" becomes "http://[::1]:11434".
 func hostURL(host string) string {
+	scheme, hostport, ok := strings.Cut(strings.TrimSpace(host), `://`)
+	defaultPort := `11434`
 	switch {
-	case strings.Contains(host, `://`):
-		// We assume it's a URL.
-		return strings.TrimSuffix(host, `/`)
-	default:
-		return `http://` + host
+	case !ok:
+		scheme, hostport = `http`, scheme
+	case scheme == `http`:
+		defaultPort = `80`
+	case scheme == `https`:
+		defaultPort = `443`
+	}
+	hostport, path, _ := strings.Cut(hostport, `/`)
+	hostname, port, err := net.SplitHostPort(hostport)
+	if err != nil {
+		hostname, port = `127.0.0.1`, defaultPort
+		if ip := net.ParseIP(strings.Trim(hostport, `[]`)); ip != nil {
+			hostname = ip.String()
+		} else if hostport != `` {
+			hostname = hostport
+		}
+	}
+	if hostname == `` {
+		hostname = `127.0.0.1`
 	}
+	u := url.URL{Scheme: scheme, Host: net.JoinHostPort(hostname, port), Path: path}
+	return strings.TrimSuffix(u.String(), `/`)
 }
 
 // Apply constructs an option that applies the provided options.
@@ -308,3 +1204,41 @@ func from(ctx context.Context) *Client {
 }
 
 type ctxClient struct{}
+
+// RequestFromContext returns the request passed to Client.Do, such as a *chat.Request, from the context of an HTTP
+// request.  This lets request hooks make decisions based on the model or messages without parsing the request body.
+func RequestFromContext(ctx context.Context) (any, bool) {
+	req := ctx.Value(ctxRequest{})
+	return req, req != nil
+}
+
+type ctxRequest struct{}
+
+// WithHeader returns a context that adds a header to requests sent to Ollama with it, such as an X-Request-ID or tenant
+// identifier for a gateway.  Headers added this way are set before request hooks are called.
+func WithHeader(ctx context.Context, key, value string) context.Context {
+	header, _ := ctx.Value(ctxHeader{}).(http.Header)
+	header = header.Clone()
+	if header == nil {
+		header = make(http.Header)
+	}
+	header.Add(key, value)
+	return context.WithValue(ctx, ctxHeader{}, header)
+}
+
+type ctxHeader struct{}
+
+// WithRequestID returns a context that correlates requests sent to Ollama with it using the id, which is sent as the
+// X-Request-ID header, logged by TraceZerolog, and included in any *Error as its RequestID.  This makes it possible to
+// find a failed call in the logs of the client and a proxy in front of Ollama.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, ctxRequestID{}, id)
+}
+
+// requestID returns the id provided by WithRequestID, if any.
+func requestID(ctx context.Context) string {
+	id, _ := ctx.Value(ctxRequestID{}).(string)
+	return id
+}
+
+type ctxRequestID struct{}
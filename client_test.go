@@ -0,0 +1,1587 @@
+package ollama
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/swdunlop/ollama-client/chat"
+	"github.com/swdunlop/ollama-client/chat/protocol"
+	"github.com/swdunlop/ollama-client/chat/tool"
+	"github.com/swdunlop/ollama-client/chat/toolkit"
+	"github.com/swdunlop/ollama-client/create"
+	"github.com/swdunlop/ollama-client/embed"
+	"github.com/swdunlop/ollama-client/generate"
+	"github.com/swdunlop/ollama-client/pull"
+)
+
+func TestDoDelete(t *testing.T) {
+	var method, contentType, body string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method = r.Method
+		contentType = r.Header.Get(`Content-Type`)
+		content, _ := io.ReadAll(r.Body)
+		body = string(content)
+	}))
+	defer srv.Close()
+
+	req := struct {
+		Model string `json:"model"`
+	}{Model: `llama3.1:latest`}
+	err := New(Host(srv.URL)).Do(context.Background(), nil, `DELETE`, &req, `/api/delete`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if method != `DELETE` {
+		t.Errorf(`expected DELETE, got %q`, method)
+	}
+	if contentType != `application/json` {
+		t.Errorf(`expected application/json content, got %q`, contentType)
+	}
+	if body != `{"model":"llama3.1:latest"}` {
+		t.Errorf(`expected {"model":"llama3.1:latest"}, got %q`, body)
+	}
+}
+
+func TestDoCancelDuringDecode(t *testing.T) {
+	done := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(`Content-Type`, `application/json`)
+		w.WriteHeader(200)
+		w.Write([]byte(`{"model":`))
+		w.(http.Flusher).Flush()
+		<-done // stall until the test is over.
+	}))
+	defer srv.Close()
+	defer close(done)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	var rsp map[string]any
+	err := New(Host(srv.URL)).Do(ctx, &rsp, `GET`, nil, `/api/tags`)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf(`expected context.DeadlineExceeded, got %v`, err)
+	}
+}
+
+func TestEmbedAll(t *testing.T) {
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		var req embed.Request
+		json.NewDecoder(r.Body).Decode(&req)
+		var rsp embed.Response
+		for _, input := range req.Input {
+			rsp.Embeddings = append(rsp.Embeddings, []float32{float32(len(input))})
+		}
+		rsp.PromptEvalCount = int64(len(req.Input))
+		json.NewEncoder(w).Encode(rsp)
+	}))
+	defer srv.Close()
+
+	ctx := With(context.Background(), Host(srv.URL))
+	rsp, err := EmbedAll(ctx, embed.BatchSize(2), embed.Input(`a`, `bb`, `ccc`, `dddd`, `eeeee`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if requests != 3 {
+		t.Errorf(`expected 3 requests, got %v`, requests)
+	}
+	if rsp.PromptEvalCount != 5 {
+		t.Errorf(`expected a prompt eval count of 5, got %v`, rsp.PromptEvalCount)
+	}
+	if len(rsp.Embeddings) != 5 {
+		t.Fatalf(`expected 5 embeddings, got %v`, len(rsp.Embeddings))
+	}
+	for i, embedding := range rsp.Embeddings {
+		if embedding[0] != float32(i+1) {
+			t.Errorf(`expected embedding %v to be %v, got %v`, i, i+1, embedding[0])
+		}
+	}
+}
+
+func TestEmbedOnVector(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req embed.Request
+		json.NewDecoder(r.Body).Decode(&req)
+		rsp := embed.Response{Model: req.Model, PromptEvalCount: int64(len(req.Input))}
+		for _, input := range req.Input {
+			rsp.Embeddings = append(rsp.Embeddings, []float32{float32(len(input)), 0.5})
+		}
+		json.NewEncoder(w).Encode(rsp)
+	}))
+	defer srv.Close()
+
+	ctx := With(context.Background(), Host(srv.URL))
+	var got []string
+	rsp, err := EmbedAll(ctx, embed.Model(`nomic-embed-text`), embed.BatchSize(2),
+		embed.Input(`a`, `bb`, `ccc`), embed.OnVector(func(i int, vec []float32) {
+			got = append(got, fmt.Sprint(i, vec))
+		}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := strings.Join(got, ` `); s != `0 [1 0.5] 1 [2 0.5] 2 [3 0.5]` {
+		t.Errorf(`unexpected vectors %v`, s)
+	}
+	if len(rsp.Embeddings) != 0 || rsp.PromptEvalCount != 3 || rsp.Model != `nomic-embed-text` {
+		t.Errorf(`expected a response without embeddings, got %#v`, rsp)
+	}
+}
+
+func TestHostURL(t *testing.T) {
+	for _, test := range []struct{ host, url string }{
+		{`localhost`, `http://localhost:11434`},
+		{`localhost:8080`, `http://localhost:8080`},
+		{`:11434`, `http://127.0.0.1:11434`},
+		{``, `http://127.0.0.1:11434`},
+		{`0.0.0.0`, `http://0.0.0.0:11434`},
+		{`::1`, `http://[::1]:11434`},
+		{`[::1]`, `http://[::1]:11434`},
+		{`[::1]:8080`, `http://[::1]:8080`},
+		{`http://example.com`, `http://example.com:80`},
+		{`https://example.com`, `https://example.com:443`},
+		{`https://example.com:8443/`, `https://example.com:8443`},
+		{`https://example.com/ollama/`, `https://example.com:443/ollama`},
+		{`http://localhost:11434`, `http://localhost:11434`},
+	} {
+		if url := hostURL(test.host); url != test.url {
+			t.Errorf(`expected %q to be %q, got %q`, test.host, test.url, url)
+		}
+	}
+}
+
+func TestDoReusesConnectionAfterError(t *testing.T) {
+	var connections atomic.Int32
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `model not found`, 500)
+	}))
+	srv.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			connections.Add(1)
+		}
+	}
+	srv.Start()
+	defer srv.Close()
+
+	client := New(Host(srv.URL))
+	for i := 0; i < 3; i++ {
+		err := client.Do(context.Background(), nil, `GET`, nil, `/api/tags`)
+		var oerr *Error
+		if !errors.As(err, &oerr) || oerr.StatusCode != 500 {
+			t.Fatalf(`expected a 500 error, got %v`, err)
+		}
+	}
+	if n := connections.Load(); n != 1 {
+		t.Errorf(`expected one connection to be reused, got %v connections`, n)
+	}
+}
+
+func TestRoundTripHook(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{}`))
+	}))
+	defer srv.Close()
+
+	var trace []string
+	hook := func(name string) Option {
+		return RoundTripHook(func(next RoundTrip) RoundTrip {
+			return func(req *http.Request) (*http.Response, error) {
+				trace = append(trace, name+` `+req.URL.Path)
+				rsp, err := next(req)
+				if err == nil {
+					trace = append(trace, name+` `+rsp.Status)
+				}
+				return rsp, err
+			}
+		})
+	}
+	err := New(Host(srv.URL), hook(`outer`), hook(`inner`)).Do(context.Background(), nil, `GET`, nil, `/api/tags`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := []string{`outer /api/tags`, `inner /api/tags`, `inner 200 OK`, `outer 200 OK`}
+	if !slices.Equal(trace, expect) {
+		t.Fatalf(`expected %q, got %q`, expect, trace)
+	}
+}
+
+func TestCreateModel(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req create.Request
+		json.NewDecoder(r.Body).Decode(&req)
+		if req.Model != `mario` || req.From != `llama3.1` || !req.Stream {
+			t.Errorf(`unexpected request %#v`, req)
+		}
+		w.Write([]byte("{\"status\":\"using existing layer\"}\n{\"status\":\"success\"}\n"))
+		if req.System == `fail` {
+			w.Write([]byte("{\"error\":\"out of disk\"}\n"))
+		}
+	}))
+	defer srv.Close()
+
+	ctx := With(context.Background(), Host(srv.URL))
+	var statuses []string
+	progress := create.Progress(func(rsp *create.Response) { statuses = append(statuses, rsp.Status) })
+	err := CreateModel(ctx, `mario`, create.From(`llama3.1`), create.System(`you are mario`), progress)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expect := []string{`using existing layer`, `success`}; !slices.Equal(statuses, expect) {
+		t.Errorf(`expected %q, got %q`, expect, statuses)
+	}
+	err = CreateModel(ctx, `mario`, create.From(`llama3.1`), create.System(`fail`))
+	if err == nil || err.Error() != `out of disk` {
+		t.Errorf(`expected the streamed error, got %v`, err)
+	}
+}
+
+func TestDoRawBody(t *testing.T) {
+	var contentType, body string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		contentType = r.Header.Get(`Content-Type`)
+		content, _ := io.ReadAll(r.Body)
+		body = string(content)
+	}))
+	defer srv.Close()
+
+	raw := &RawBody{ContentType: `text/plain`, Body: strings.NewReader(`{not json}`)}
+	err := New(Host(srv.URL)).Do(context.Background(), nil, `POST`, raw, `/api/raw`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if contentType != `text/plain` {
+		t.Errorf(`expected text/plain content, got %q`, contentType)
+	}
+	if body != `{not json}` {
+		t.Errorf(`expected {not json}, got %q`, body)
+	}
+}
+
+func TestErrorIs(t *testing.T) {
+	notFound := &Error{StatusCode: 404, Status: `404 Not Found`,
+		Content: []byte(`{"error":"model \"llama9\" not found, try pulling it first"}`)}
+	busy := &Error{StatusCode: 503, Status: `503 Service Unavailable`, Content: []byte(`{"error":"server busy"}`)}
+	limited := &Error{StatusCode: 429, Status: `429 Too Many Requests`}
+	for _, test := range []struct {
+		err    error
+		target error
+		expect bool
+	}{
+		{notFound, ErrModelNotFound, true},
+		{notFound, ErrModelLoading, false},
+		{busy, ErrModelLoading, true},
+		{busy, ErrModelNotFound, false},
+		{limited, ErrRateLimited, true},
+		{fmt.Errorf(`%w while chatting`, notFound), ErrModelNotFound, true},
+	} {
+		if errors.Is(test.err, test.target) != test.expect {
+			t.Errorf(`expected errors.Is(%v, %v) to be %v`, test.err, test.target, test.expect)
+		}
+	}
+}
+
+func TestRedactors(t *testing.T) {
+	msg := json.RawMessage(`{"model":"llama3.1","messages":[{"role":"user","content":"my password is hunter2"}]}`)
+	if js := string(RedactFields(`content`)(msg)); js != `{"messages":[{"content":"[REDACTED]","role":"user"}],"model":"llama3.1"}` {
+		t.Errorf(`unexpected redaction %v`, js)
+	}
+	if js := string(TruncateStrings(5)(msg)); js != `{"messages":[{"content":"my pa...","role":"user"}],"model":"llama..."}` {
+		t.Errorf(`unexpected truncation %v`, js)
+	}
+	if js := string(TruncateStrings(-1)(msg)); js != `{"messages":[{"content":"...","role":"..."}],"model":"..."}` {
+		t.Errorf(`unexpected truncation with a negative limit %v`, js)
+	}
+}
+
+func TestRequestFromContext(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"message":{"role":"assistant","content":"hi"}}`))
+	}))
+	defer srv.Close()
+
+	var model string
+	ctx := With(context.Background(), Host(srv.URL), RequestHook(func(hreq *http.Request) error {
+		req, _ := RequestFromContext(hreq.Context())
+		if req, ok := req.(*chat.Request); ok {
+			model = req.Model
+		}
+		return nil
+	}))
+	_, err := Chat(ctx, chat.Model(`llama3.1`), chat.User(`hi`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if model != `llama3.1` {
+		t.Errorf(`expected the hook to see the llama3.1 model, got %q`, model)
+	}
+}
+
+func TestWithHeader(t *testing.T) {
+	var tenant, requestID string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tenant, requestID = r.Header.Get(`X-Tenant`), r.Header.Get(`X-Request-ID`)
+	}))
+	defer srv.Close()
+
+	ctx := WithHeader(context.Background(), `X-Tenant`, `acme`)
+	err := New(Host(srv.URL)).Do(WithHeader(ctx, `X-Request-ID`, `42`), nil, `GET`, nil, `/api/tags`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tenant != `acme` || requestID != `42` {
+		t.Errorf(`expected acme and 42, got %q and %q`, tenant, requestID)
+	}
+}
+
+func TestWithHeaderHooks(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tags := r.Header.Values(`X-Tag`)
+		expect := []string{`a`, `b`, `c`, r.URL.Query().Get(`n`)}
+		if !slices.Equal(tags, expect) {
+			t.Errorf(`expected %q, got %q`, expect, tags)
+		}
+	}))
+	defer srv.Close()
+
+	// After three values, the slice for X-Tag has spare capacity that a hook adding a value could write into.
+	ctx := WithHeader(WithHeader(WithHeader(context.Background(), `X-Tag`, `a`), `X-Tag`, `b`), `X-Tag`, `c`)
+	client := New(Host(srv.URL), RoundTripHook(func(next RoundTrip) RoundTrip {
+		return func(req *http.Request) (*http.Response, error) {
+			req.Header.Add(`X-Tag`, req.URL.Query().Get(`n`))
+			return next(req)
+		}
+	}))
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := client.Do(ctx, nil, `GET`, nil, fmt.Sprintf(`/api/tags?n=%v`, i))
+			if err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestWithRequestID(t *testing.T) {
+	var header string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header.Get(`X-Request-ID`)
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer srv.Close()
+
+	var log strings.Builder
+	client := New(Host(srv.URL), TraceZerolog(zerolog.New(&log).Level(zerolog.TraceLevel)))
+	err := client.Do(WithRequestID(context.Background(), `req-42`), nil, `GET`, nil, `/api/tags`)
+	var oerr *Error
+	if !errors.As(err, &oerr) || oerr.RequestID != `req-42` {
+		t.Errorf(`expected an error with the request id, got %#v`, err)
+	}
+	if header != `req-42` {
+		t.Errorf(`expected the request id to be sent, got %q`, header)
+	}
+	if n := strings.Count(log.String(), `"request_id":"req-42"`); n != 2 {
+		t.Errorf(`expected the request id in the request and response logs, got %v`, log.String())
+	}
+}
+
+func TestGenerateStream(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req generate.Request
+		json.NewDecoder(r.Body).Decode(&req)
+		if !req.Stream || !slices.Equal(req.Context, []int{1, 2}) {
+			t.Errorf(`unexpected request %#v`, req)
+		}
+		w.Write([]byte("{\"response\":\"Hello\"}\n{\"response\":\", world\"}\n"))
+		w.Write([]byte("{\"response\":\"\",\"done\":true,\"context\":[1,2,3,4]}\n"))
+	}))
+	defer srv.Close()
+
+	ctx := With(context.Background(), Host(srv.URL), Model(`llama3.1`))
+	var chunks []string
+	rsp, err := Generate(ctx, generate.Prompt(`hi`), generate.Context([]int{1, 2}),
+		generate.Stream(func(rsp *generate.Response) error {
+			chunks = append(chunks, rsp.Response)
+			return nil
+		}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expect := []string{`Hello`, `, world`, ``}; !slices.Equal(chunks, expect) {
+		t.Errorf(`expected %q, got %q`, expect, chunks)
+	}
+	if rsp.Response != `Hello, world` || !rsp.Done {
+		t.Errorf(`expected a done response of "Hello, world", got %#v`, rsp)
+	}
+	if !slices.Equal(rsp.Context, []int{1, 2, 3, 4}) {
+		t.Errorf(`expected the final context to be preserved, got %v`, rsp.Context)
+	}
+}
+
+func TestPing(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != `/api/version` {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(`{"version":"0.5.1"}`))
+	}))
+	defer srv.Close()
+	ctx := With(context.Background(), Host(srv.URL))
+	err := Ping(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = Ping(With(ctx, Host(srv.URL+`/missing`)))
+	var oerr *Error
+	if !errors.As(err, &oerr) || oerr.StatusCode != 404 {
+		t.Errorf(`expected a 404 *Error, got %v`, err)
+	}
+	srv.Close()
+	err = Ping(ctx)
+	if err == nil || errors.As(err, &oerr) {
+		t.Errorf(`expected a connection error after the server was closed, got %v`, err)
+	}
+	if err != nil && !strings.Contains(err.Error(), srv.URL) {
+		t.Errorf(`expected the error to name the server, got %v`, err)
+	}
+}
+
+func TestLoadModel(t *testing.T) {
+	var model, keepAlive string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Model     string          `json:"model"`
+			KeepAlive json.RawMessage `json:"keep_alive"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		model, keepAlive = req.Model, string(req.KeepAlive)
+		w.Write([]byte(`{"model":"llama3.1","done":true,"load_duration":1500}`))
+	}))
+	defer srv.Close()
+
+	ctx := With(context.Background(), Host(srv.URL))
+	for _, test := range []struct {
+		keepAlive time.Duration
+		expect    string
+	}{
+		{-1, `-1`},
+		{0, `0`},
+		{5 * time.Minute, `"5m0s"`},
+	} {
+		d, err := LoadModel(ctx, `llama3.1`, test.keepAlive)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if d != 1500 {
+			t.Errorf(`expected a load duration of 1500ns, got %v`, d)
+		}
+		if keepAlive != test.expect {
+			t.Errorf(`expected keep_alive %v for %v, got %v`, test.expect, test.keepAlive, keepAlive)
+		}
+	}
+
+	model = ``
+	_, err := LoadModel(With(ctx, Model(`qwen2.5`)), ``, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if model != `qwen2.5` {
+		t.Errorf(`expected the model from the client, got %q`, model)
+	}
+	model = ``
+	_, err = LoadModel(ctx, ``, time.Minute)
+	if err == nil || model != `` {
+		t.Errorf(`expected an error without sending a request when there is no model, got %v`, err)
+	}
+}
+
+type testLimiter struct{ waits atomic.Int32 }
+
+func (l *testLimiter) Wait(ctx context.Context) error {
+	if l.waits.Add(1) > 2 {
+		return errors.New(`rate limited`)
+	}
+	return nil
+}
+
+func TestRateLimit(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	limiter := new(testLimiter)
+	client := New(Host(srv.URL), RateLimit(limiter))
+	derived := client.Apply(Model(`llama3.1`))
+	for i, ct := range []*Client{client, derived, client} {
+		err := ct.Do(context.Background(), nil, `GET`, nil, `/api/tags`)
+		if (err != nil) != (i == 2) {
+			t.Errorf(`unexpected error for request %v: %v`, i, err)
+		}
+	}
+}
+
+func TestApplyClonesHooks(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	nop := func(*http.Request) error { return nil }
+	// Three hooks leave spare capacity in the slice, so appending to it without cloning would share the array.
+	base := New(Host(srv.URL), RequestHook(nop), RequestHook(nop), RequestHook(nop))
+	mark := func(name string) Option {
+		return RequestHook(func(hreq *http.Request) error {
+			hreq.Header.Set(`X-Derived`, name)
+			return nil
+		})
+	}
+	first := base.Apply(mark(`first`))
+	second := base.Apply(mark(`second`))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		for _, ct := range []*Client{first, second} {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				err := ct.Do(context.Background(), nil, `GET`, nil, `/api/version`)
+				if err != nil {
+					t.Error(err)
+				}
+			}()
+		}
+	}
+	wg.Wait()
+
+	var got string
+	check := first.Apply(RequestHook(func(hreq *http.Request) error {
+		got = hreq.Header.Get(`X-Derived`)
+		return nil
+	}))
+	if err := check.Do(context.Background(), nil, `GET`, nil, `/api/version`); err != nil {
+		t.Fatal(err)
+	}
+	if got != `first` {
+		t.Errorf(`expected the hook of the first derived client, got %q`, got)
+	}
+}
+
+func TestEmbedder(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req embed.Request
+		json.NewDecoder(r.Body).Decode(&req)
+		if req.Model != `nomic-embed-text` {
+			t.Errorf(`expected the nomic-embed-text model, got %q`, req.Model)
+		}
+		var rsp embed.Response
+		for _, input := range req.Input {
+			rsp.Embeddings = append(rsp.Embeddings, []float32{float32(len(input))})
+		}
+		json.NewEncoder(w).Encode(rsp)
+	}))
+	defer srv.Close()
+
+	ctx := With(context.Background(), Host(srv.URL))
+	embedder := NewEmbedder(`nomic-embed-text`, embed.BatchSize(1))
+	for _, texts := range [][]string{{`a`, `bb`}, {`ccc`}} {
+		embeddings, err := embedder.Embed(ctx, texts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(embeddings) != len(texts) || embeddings[0][0] != float32(len(texts[0])) {
+			t.Errorf(`unexpected embeddings %v for %q`, embeddings, texts)
+		}
+	}
+}
+
+func TestChatReader(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req chat.Request
+		json.NewDecoder(r.Body).Decode(&req)
+		if !req.Stream {
+			t.Errorf(`expected a streaming request`)
+		}
+		w.Write([]byte("{\"message\":{\"role\":\"assistant\",\"content\":\"Hello\"}}\n"))
+		w.Write([]byte("{\"message\":{\"role\":\"assistant\",\"content\":\", world\"}}\n"))
+		w.Write([]byte("{\"message\":{\"role\":\"assistant\",\"content\":\"\"},\"done\":true}\n"))
+	}))
+	defer srv.Close()
+
+	ctx := With(context.Background(), Host(srv.URL), Model(`llama3.1`))
+	r, err := ChatReader(ctx, chat.User(`hi`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	content, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != `Hello, world` {
+		t.Errorf(`expected "Hello, world", got %q`, content)
+	}
+}
+
+func TestPullModel(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req pull.Request
+		json.NewDecoder(r.Body).Decode(&req)
+		w.Write([]byte("{\"status\":\"pulling manifest\"}\n"))
+		w.Write([]byte("{\"status\":\"pulling aaa\",\"digest\":\"sha256:aaa\",\"total\":10,\"completed\":10}\n"))
+		w.Write([]byte("{\"status\":\"pulling bbb\",\"digest\":\"sha256:bbb\",\"total\":10,\"completed\":4}\n"))
+		if req.Model == `flaky` {
+			return // the connection drops before the pull is finished.
+		}
+		w.Write([]byte("{\"status\":\"pulling bbb\",\"digest\":\"sha256:bbb\",\"total\":10,\"completed\":10}\n"))
+		w.Write([]byte("{\"status\":\"verifying sha256 digest\"}\n{\"status\":\"success\"}\n"))
+	}))
+	defer srv.Close()
+
+	ctx := With(context.Background(), Host(srv.URL))
+	var layers []string
+	onLayer := pull.OnLayer(func(digest string, done bool) { layers = append(layers, fmt.Sprint(digest, ` `, done)) })
+	err := PullModel(ctx, `llama3.1`, onLayer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := []string{`sha256:aaa false`, `sha256:aaa true`, `sha256:bbb false`, `sha256:bbb true`}
+	if !slices.Equal(layers, expect) {
+		t.Errorf(`expected %q, got %q`, expect, layers)
+	}
+	err = PullModel(ctx, `flaky`)
+	if err == nil {
+		t.Errorf(`expected an error for a pull that did not succeed`)
+	}
+}
+
+func TestUseNumber(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"id":9007199254740993}`))
+	}))
+	defer srv.Close()
+
+	var rsp map[string]any
+	err := New(Host(srv.URL), UseNumber()).Do(context.Background(), &rsp, `GET`, nil, `/api/ids`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id, ok := rsp[`id`].(json.Number); !ok || id.String() != `9007199254740993` {
+		t.Errorf(`expected the id as a precise json.Number, got %#v`, rsp[`id`])
+	}
+}
+
+func TestChatStreamHook(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req chat.Request
+		json.NewDecoder(r.Body).Decode(&req)
+		if !req.Stream {
+			t.Errorf(`expected a streaming request`)
+		}
+		w.Write([]byte("{\"message\":{\"role\":\"assistant\",\"content\":\"Hel\"}}\n"))
+		w.Write([]byte("{\"message\":{\"role\":\"assistant\",\"content\":\"lo\"}}\n"))
+		w.Write([]byte("{\"message\":{\"role\":\"assistant\",\"content\":\"\"},\"done\":true,\"eval_count\":2}\n"))
+	}))
+	defer srv.Close()
+
+	ctx := With(context.Background(), Host(srv.URL), Model(`llama3.1`))
+	var deltas []string
+	rsp, err := Chat(ctx, chat.User(`hi`), chat.StreamHook(func(ctx context.Context, delta *chat.Response) error {
+		deltas = append(deltas, delta.Message.Content)
+		return nil
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expect := []string{`Hel`, `lo`, ``}; !slices.Equal(deltas, expect) {
+		t.Errorf(`expected %q, got %q`, expect, deltas)
+	}
+	if rsp.Message.Content != `Hello` || !rsp.Done || rsp.EvalCount != `2` {
+		t.Errorf(`expected the combined response, got %#v`, rsp)
+	}
+
+	_, err = Chat(ctx, chat.User(`hi`), chat.StreamHook(func(ctx context.Context, delta *chat.Response) error {
+		return errors.New(`enough`)
+	}))
+	if err == nil || err.Error() != `enough` {
+		t.Errorf(`expected the hook error, got %v`, err)
+	}
+}
+
+func TestChatStreamHookTools(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req chat.Request
+		json.NewDecoder(r.Body).Decode(&req)
+		if !req.Stream || len(req.Tools) != 1 {
+			t.Errorf(`expected a streaming request with tools, got %#v`, req)
+		}
+		if len(req.Messages) > 1 {
+			w.Write([]byte("{\"message\":{\"role\":\"assistant\",\"content\":\"42\"},\"done\":true}\n"))
+			return
+		}
+		w.Write([]byte("{\"message\":{\"role\":\"assistant\",\"tool_calls\":[{\"function\":{\"name\":\"answer\",\"arguments\":{}}}]}}\n"))
+		w.Write([]byte("{\"message\":{\"role\":\"assistant\",\"content\":\"\"},\"done\":true}\n"))
+	}))
+	defer srv.Close()
+
+	answer, err := tool.New(tool.Name(`answer`), tool.Description(`answers the question`),
+		tool.Func(func(q struct{}) int { return 42 }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := With(context.Background(), Host(srv.URL), Model(`llama3.1`))
+	rsp, err := Chat(ctx, chat.User(`what is the answer?`), chat.Toolkit(toolkit.New(answer)),
+		chat.StreamHook(func(ctx context.Context, delta *chat.Response) error { return nil }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rsp.Message.Content != `42` {
+		t.Errorf(`expected the response after the tool call, got %#v`, rsp)
+	}
+
+	_, err = ChatReader(ctx, chat.User(`what is the answer?`), chat.Toolkit(toolkit.New(answer)))
+	var invalid *chat.InvalidRequestError
+	if !errors.As(err, &invalid) {
+		t.Errorf(`expected ChatReader to reject tools, got %v`, err)
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	done := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-done:
+		case <-r.Context().Done():
+		}
+	}))
+	defer srv.Close()
+	defer close(done)
+
+	start := time.Now()
+	err := New(Host(srv.URL), Timeout(50*time.Millisecond)).Do(context.Background(), nil, `GET`, nil, `/api/tags`)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf(`expected context.DeadlineExceeded, got %v`, err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf(`expected the timeout to end the request promptly, took %v`, elapsed)
+	}
+}
+
+func TestRequireCapability(t *testing.T) {
+	var shows atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case `/api/show`:
+			shows.Add(1)
+			w.Write([]byte(`{"capabilities":["completion","tools"]}`))
+		case `/api/chat`:
+			w.Write([]byte(`{"message":{"role":"assistant","content":"hi"}}`))
+		}
+	}))
+	defer srv.Close()
+
+	ctx := With(context.Background(), Host(srv.URL), Model(`llama3.1`))
+	for i := 0; i < 2; i++ {
+		_, err := Chat(ctx, chat.User(`hi`), chat.RequireCapability(`tools`))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := shows.Load(); n != 1 {
+		t.Errorf(`expected the capabilities to be cached, got %v lookups`, n)
+	}
+	_, err := Chat(ctx, chat.User(`hi`), chat.RequireCapability(`vision`))
+	if !errors.Is(err, chat.ErrMissingCapability) {
+		t.Errorf(`expected chat.ErrMissingCapability, got %v`, err)
+	}
+}
+
+type countingCodec struct{ marshals, decoders int }
+
+func (c *countingCodec) Marshal(v any) ([]byte, error) {
+	c.marshals++
+	return json.Marshal(v)
+}
+
+func (c *countingCodec) NewDecoder(r io.Reader) Decoder {
+	c.decoders++
+	return json.NewDecoder(r)
+}
+
+func TestWithCodec(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"embeddings":[[0.5]]}`))
+	}))
+	defer srv.Close()
+
+	codec := new(countingCodec)
+	ctx := With(context.Background(), Host(srv.URL), WithCodec(codec))
+	rsp, err := Embed(ctx, embed.Model(`nomic-embed-text`), embed.Input(`a`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rsp.Embeddings) != 1 || rsp.Embeddings[0][0] != 0.5 {
+		t.Errorf(`unexpected embeddings %v`, rsp.Embeddings)
+	}
+	if codec.marshals != 1 || codec.decoders != 1 {
+		t.Errorf(`expected the codec to be used once each, got %v marshals and %v decoders`, codec.marshals, codec.decoders)
+	}
+}
+
+func embedServer(dims int) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req embed.Request
+		json.NewDecoder(r.Body).Decode(&req)
+		var rsp embed.Response
+		for range req.Input {
+			rsp.Embeddings = append(rsp.Embeddings, make([]float32, dims))
+		}
+		json.NewEncoder(w).Encode(rsp)
+	}))
+}
+
+func TestEmbedInto(t *testing.T) {
+	srv := embedServer(4)
+	defer srv.Close()
+
+	ctx := With(context.Background(), Host(srv.URL))
+	buf := make([][]float32, 3)
+	for i := range buf {
+		buf[i] = make([]float32, 4)
+	}
+	rsp, err := EmbedAll(ctx, embed.Into(buf), embed.BatchSize(2), embed.Input(`a`, `b`, `c`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rsp.Embeddings) != 3 {
+		t.Fatalf(`expected 3 embeddings, got %v`, len(rsp.Embeddings))
+	}
+	for i, embedding := range rsp.Embeddings {
+		if &embedding[0] != &buf[i][0] {
+			t.Errorf(`expected embedding %v to reuse the buffer`, i)
+		}
+	}
+}
+
+func BenchmarkEmbed(b *testing.B) {
+	srv := embedServer(768)
+	defer srv.Close()
+	ctx := With(context.Background(), Host(srv.URL))
+	inputs := embed.Input(`a`, `b`, `c`, `d`, `e`, `f`, `g`, `h`)
+
+	b.Run(`Fresh`, func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			_, err := Embed(ctx, inputs)
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run(`Into`, func(b *testing.B) {
+		b.ReportAllocs()
+		var buf [][]float32
+		for i := 0; i < b.N; i++ {
+			rsp, err := Embed(ctx, inputs, embed.Into(buf))
+			if err != nil {
+				b.Fatal(err)
+			}
+			buf = rsp.Embeddings
+		}
+	})
+}
+
+func TestEmbedStream(t *testing.T) {
+	srv := embedServer(2)
+	defer srv.Close()
+
+	ctx := With(context.Background(), Host(srv.URL))
+	inputs := make(chan string)
+	go func() {
+		defer close(inputs)
+		for i := 0; i < 5; i++ {
+			inputs <- fmt.Sprint(i)
+		}
+	}()
+	var indices []int
+	for result := range EmbedStream(ctx, inputs, embed.BatchSize(2)) {
+		if result.Err != nil {
+			t.Fatal(result.Err)
+		}
+		if len(result.Embedding) != 2 {
+			t.Errorf(`expected an embedding with 2 dimensions, got %v`, result.Embedding)
+		}
+		indices = append(indices, result.Index)
+	}
+	if expect := []int{0, 1, 2, 3, 4}; !slices.Equal(indices, expect) {
+		t.Errorf(`expected results for %v, got %v`, expect, indices)
+	}
+	inputs = make(chan string, 1)
+	inputs <- `0`
+	close(inputs)
+	var results []EmbedResult
+	for result := range EmbedStream(ctx, inputs, embed.Into(make([][]float32, 2))) {
+		results = append(results, result)
+	}
+	if len(results) != 1 || results[0].Err == nil {
+		t.Errorf(`expected embed.Into to be rejected, got %#v`, results)
+	}
+}
+
+func TestAutoNumCtx(t *testing.T) {
+	var numCtx []any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case `/api/show`:
+			w.Write([]byte(`{"model_info":{"llama.context_length":8192}}`))
+		case `/api/chat`:
+			var req chat.Request
+			json.NewDecoder(r.Body).Decode(&req)
+			numCtx = append(numCtx, req.Options[`num_ctx`])
+			w.Write([]byte(`{"message":{"role":"assistant","content":"ok"}}`))
+		}
+	}))
+	defer srv.Close()
+
+	ctx := With(context.Background(), Host(srv.URL), Model(`llama3.1`))
+	for _, prompt := range []string{`hi`, strings.Repeat(`word `, 2000), strings.Repeat(`word `, 100000)} {
+		_, err := Chat(ctx, chat.User(prompt), chat.AutoNumCtx())
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	// JSON numbers decode as float64.
+	if expect := []any{2048.0, 4096.0, 8192.0}; !slices.Equal(numCtx, expect) {
+		t.Errorf(`expected num_ctx of %v, got %v`, expect, numCtx)
+	}
+}
+
+func TestChatInto(t *testing.T) {
+	var format json.RawMessage
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Format json.RawMessage `json:"format"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		format = req.Format
+		w.Write([]byte(`{"message":{"role":"assistant","content":"{\"city\":\"Paris\",\"population\":2100000,\"capital\":true}"}}`))
+	}))
+	defer srv.Close()
+
+	type City struct {
+		Name       string `json:"city"       use:"name of the city"`
+		Population int    `json:"population" use:"number of residents"`
+		Capital    bool   `json:"capital"    use:"true if the city is a capital"`
+	}
+	ctx := With(context.Background(), Host(srv.URL), Model(`llama3.1`))
+	city, rsp, err := ChatInto[City](ctx, chat.User(`what is the capital of France?`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if city.Name != `Paris` || city.Population != 2100000 || !city.Capital || rsp == nil {
+		t.Errorf(`unexpected city %#v`, city)
+	}
+	expect := `{"type":"object","description":"","properties":{` +
+		`"capital":{"type":"boolean","description":"true if the city is a capital"},` +
+		`"city":{"type":"string","description":"name of the city"},` +
+		`"population":{"type":"number","description":"number of residents"}},"required":["city","population","capital"]}`
+	if string(format) != expect {
+		t.Errorf("expected format %v\ngot %v", expect, string(format))
+	}
+}
+
+func TestChatDeadline(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"message":{"role":"assistant","tool_calls":[{"function":{"name":"wait","arguments":{}}}]}}`))
+	}))
+	defer srv.Close()
+
+	wait, err := tool.New(tool.Name(`wait`), tool.Description(`waits for a while`), tool.Func(func(ctx context.Context, q struct{}) (string, error) {
+		select {
+		case <-time.After(20 * time.Millisecond):
+			return `done`, nil
+		case <-ctx.Done():
+			return ``, ctx.Err()
+		}
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := With(context.Background(), Host(srv.URL), Model(`llama3.1`))
+	rsp, err := Chat(ctx, chat.User(`wait forever`), chat.Toolkit(toolkit.New(wait)), chat.Deadline(100*time.Millisecond))
+	if !errors.Is(err, chat.ErrDeadlineExceeded) {
+		t.Fatalf(`expected chat.ErrDeadlineExceeded, got %v`, err)
+	}
+	if rsp == nil || len(rsp.Message.ToolCalls) != 1 {
+		t.Errorf(`expected the last response, got %#v`, rsp)
+	}
+}
+
+func TestChatDebug(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"message":{"role":"assistant","content":"hi"}}`))
+	}))
+	defer srv.Close()
+
+	var buf strings.Builder
+	ctx := With(context.Background(), Host(srv.URL), Model(`llama3.1`))
+	_, err := Chat(ctx, chat.User(`hi`), chat.Debug(&buf))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), `"model": "llama3.1"`) {
+		t.Errorf(`expected the debug output to include the default model, got %v`, buf.String())
+	}
+}
+
+func TestChatToolErrorMidBatch(t *testing.T) {
+	var sent []protocol.Message
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req chat.Request
+		json.NewDecoder(r.Body).Decode(&req)
+		if len(req.Messages) > 1 {
+			sent = req.Messages
+			w.Write([]byte(`{"message":{"role":"assistant","content":"two of three"}}`))
+			return
+		}
+		w.Write([]byte(`{"message":{"role":"assistant","tool_calls":[` +
+			`{"function":{"name":"lookup","arguments":{"key":"a"}}},` +
+			`{"function":{"name":"lookup","arguments":{"key":"bad"}}},` +
+			`{"function":{"name":"lookup","arguments":{"key":"c"}}}]}}`))
+	}))
+	defer srv.Close()
+
+	lookup, err := tool.New(tool.Name(`lookup`), tool.Description(`looks up a key`),
+		tool.Func(func(q struct {
+			Key string `json:"key" use:"key to look up"`
+		}) (string, error) {
+			if q.Key == `bad` {
+				return ``, errors.New(`no such key`)
+			}
+			return `value of ` + q.Key, nil
+		}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := With(context.Background(), Host(srv.URL), Model(`llama3.1`))
+	_, err = Chat(ctx, chat.User(`look up a, bad and c`), chat.Toolkit(toolkit.New(lookup)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var contents []string
+	for _, m := range sent {
+		if m.Role == protocol.TOOL {
+			contents = append(contents, m.Content)
+		}
+	}
+	expect := []string{`"value of a"`, `{"error":"no such key"}`, `"value of c"`}
+	if !slices.Equal(contents, expect) {
+		t.Errorf(`expected tool results %q, got %q`, expect, contents)
+	}
+
+	sent = nil
+	rsp, err := Chat(ctx, chat.User(`look up a, bad and c`), chat.Toolkit(toolkit.New(lookup)), chat.ToolErrorsFatal())
+	if err == nil || !strings.Contains(err.Error(), `no such key`) {
+		t.Errorf(`expected the tool error with ToolErrorsFatal, got %v`, err)
+	}
+	if rsp == nil || len(rsp.Message.ToolCalls) != 3 {
+		t.Errorf(`expected the response with the tool calls, got %#v`, rsp)
+	}
+	if sent != nil {
+		t.Errorf(`expected the chat to stop after the tool error, but it sent %#v`, sent)
+	}
+}
+
+func TestUserAgent(t *testing.T) {
+	var userAgent string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userAgent = r.Header.Get(`User-Agent`)
+	}))
+	defer srv.Close()
+
+	err := New(Host(srv.URL)).Do(context.Background(), nil, `GET`, nil, `/api/tags`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if userAgent != `ollama-client/`+Version {
+		t.Errorf(`expected the default user agent, got %q`, userAgent)
+	}
+	err = New(Host(srv.URL), UserAgent(`orderbot/1.2`)).Do(context.Background(), nil, `GET`, nil, `/api/tags`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if userAgent != `orderbot/1.2` {
+		t.Errorf(`expected orderbot/1.2, got %q`, userAgent)
+	}
+}
+
+func TestContentLength(t *testing.T) {
+	var contentLength int64
+	var transferEncoding []string
+	var body string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		contentLength, transferEncoding = r.ContentLength, r.TransferEncoding
+		content, _ := io.ReadAll(r.Body)
+		body = string(content)
+	}))
+	defer srv.Close()
+
+	req := map[string]string{`model`: `llama3.1`}
+	err := New(Host(srv.URL)).Do(context.Background(), nil, `POST`, req, `/api/show`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if contentLength != int64(len(`{"model":"llama3.1"}`)) || len(transferEncoding) != 0 {
+		t.Errorf(`expected a content length of 20 without a transfer encoding, got %v and %q`, contentLength, transferEncoding)
+	}
+
+	rewrite := RequestHook(func(hreq *http.Request) error {
+		hreq.Body = io.NopCloser(strings.NewReader(`{"model":"llama3.1:70b"}`))
+		hreq.ContentLength = -1
+		return nil
+	})
+	err = New(Host(srv.URL), rewrite).Do(context.Background(), nil, `POST`, req, `/api/show`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body != `{"model":"llama3.1:70b"}` || !slices.Equal(transferEncoding, []string{`chunked`}) {
+		t.Errorf(`expected the rewritten body to be chunked, got %q and %q`, body, transferEncoding)
+	}
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	var healthy atomic.Bool
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		if !healthy.Load() {
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+	defer srv.Close()
+
+	client := New(Host(srv.URL), CircuitBreaker(2, 50*time.Millisecond))
+	do := func() error { return client.Do(context.Background(), nil, `GET`, nil, `/api/version`) }
+	for i := 0; i < 2; i++ {
+		if err := do(); err == nil || errors.Is(err, ErrCircuitOpen) {
+			t.Fatalf(`expected request %v to reach the failing host, got %v`, i, err)
+		}
+	}
+	if err := do(); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf(`expected the circuit to be open after two failures, got %v`, err)
+	}
+	if n := hits.Load(); n != 2 {
+		t.Errorf(`expected only two requests to reach the host, got %v`, n)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	healthy.Store(true)
+	if err := do(); err != nil {
+		t.Fatalf(`expected the probe to succeed after the cooldown, got %v`, err)
+	}
+	if err := do(); err != nil {
+		t.Errorf(`expected the circuit to close after the probe, got %v`, err)
+	}
+}
+
+func TestRecordReplay(t *testing.T) {
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := hits.Add(1)
+		fmt.Fprintf(w, `{"message":{"role":"assistant","content":"reply %v"},"done":true}`, n)
+	}))
+	dir := t.TempDir()
+	ctx := With(context.Background(), Host(srv.URL), Model(`llama3.1`), Record(dir))
+	for i := 0; i < 2; i++ {
+		if _, err := Chat(ctx, chat.User(`hello`)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	srv.Close()
+
+	ctx = With(context.Background(), Host(srv.URL), Model(`llama3.1`), Replay(dir))
+	for _, expect := range []string{`reply 1`, `reply 2`, `reply 2`} {
+		rsp, err := Chat(ctx, chat.User(`hello`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rsp.Message.Content != expect {
+			t.Errorf(`expected %q to be replayed, got %q`, expect, rsp.Message.Content)
+		}
+	}
+	_, err := Chat(ctx, chat.User(`goodbye`))
+	if !errors.Is(err, ErrNotRecorded) {
+		t.Errorf(`expected ErrNotRecorded for a new request, got %v`, err)
+	}
+	if hits.Load() != 2 {
+		t.Errorf(`expected only the recorded requests to reach the server, got %v`, hits.Load())
+	}
+}
+
+func TestCacheTTL(t *testing.T) {
+	var shows atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case `/api/show`:
+			shows.Add(1)
+			w.Write([]byte(`{"capabilities":["completion","tools"]}`))
+		case `/api/pull`:
+			w.Write([]byte(`{"status":"success"}`))
+		}
+	}))
+	defer srv.Close()
+
+	ctx := With(context.Background(), Host(srv.URL), CacheTTL(50*time.Millisecond))
+	check := func(expect int32) {
+		t.Helper()
+		if _, err := ModelCapabilities(ctx, `llama3.1`); err != nil {
+			t.Fatal(err)
+		}
+		if n := shows.Load(); n != expect {
+			t.Errorf(`expected %v requests for the model, got %v`, expect, n)
+		}
+	}
+	check(1)
+	check(1)
+	time.Sleep(60 * time.Millisecond)
+	check(2)
+	if err := PullModel(ctx, `llama3.1`); err != nil {
+		t.Fatal(err)
+	}
+	check(3)
+}
+
+func TestTraceZerologBodies(t *testing.T) {
+	seen := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case `/api/short`:
+			// The body is shorter than promised, so reading it fails.
+			w.Header().Set(`Content-Type`, `application/json`)
+			w.Header().Set(`Content-Length`, `100`)
+			w.Write([]byte(`{"version":`))
+		case `/api/stream`:
+			w.Header().Set(`Content-Type`, `application/x-ndjson`)
+			w.Write([]byte("{\"n\":1}\n"))
+			w.(http.Flusher).Flush()
+			select {
+			case <-seen:
+			case <-time.After(time.Second):
+			}
+			w.Write([]byte("{\"n\":2}\n"))
+		}
+	}))
+	defer srv.Close()
+
+	var log strings.Builder
+	client := New(Host(srv.URL), TraceZerolog(zerolog.New(&log).Level(zerolog.TraceLevel)))
+	var rsp map[string]any
+	err := client.Do(context.Background(), &rsp, `GET`, nil, `/api/short`)
+	if err == nil {
+		t.Error(`expected an error for a truncated response`)
+	}
+
+	start := time.Now()
+	err = client.Stream(context.Background(), func(msg json.RawMessage) error {
+		if string(msg) == `{"n":1}` {
+			close(seen)
+		}
+		return nil
+	}, `GET`, nil, `/api/stream`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if time.Since(start) > 500*time.Millisecond {
+		t.Error(`expected the first chunk to arrive before the stream ended`)
+	}
+}
+
+func TestRecordBrokenResponse(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case `/api/short`:
+			w.Header().Set(`Content-Length`, `100`)
+			w.Write([]byte(`{"version":`))
+		case `/api/stall`:
+			w.Write([]byte(`{"version":`))
+			w.(http.Flusher).Flush()
+			<-r.Context().Done()
+		}
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	client := New(Host(srv.URL), Record(dir))
+	err := client.Do(context.Background(), nil, `GET`, nil, `/api/short`)
+	if err == nil {
+		t.Error(`expected an error for a truncated response`)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err = client.Do(ctx, nil, `GET`, nil, `/api/stall`)
+	if err == nil {
+		t.Error(`expected an error when the context is canceled while reading the response`)
+	}
+	if names, _ := filepath.Glob(filepath.Join(dir, `*.json`)); len(names) != 0 {
+		t.Errorf(`expected incomplete responses not to be recorded, got %v`, names)
+	}
+}
+
+func TestPushBlob(t *testing.T) {
+	blobs := make(map[string][]byte)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		digest := strings.TrimPrefix(r.URL.Path, `/api/blobs/`)
+		switch r.Method {
+		case `POST`:
+			content, _ := io.ReadAll(r.Body)
+			sum := sha256.Sum256(content)
+			if digest != `sha256:`+hex.EncodeToString(sum[:]) {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			blobs[digest] = content
+		case `HEAD`:
+			if _, ok := blobs[digest]; !ok {
+				w.WriteHeader(http.StatusNotFound)
+			}
+		}
+	}))
+	defer srv.Close()
+	ctx := With(context.Background(), Host(srv.URL))
+
+	f, err := os.CreateTemp(t.TempDir(), `blob`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	f.WriteString(`header:the blob content`)
+	f.Seek(int64(len(`header:`)), io.SeekStart)
+	digest, err := PushBlob(ctx, f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(blobs[digest]) != `the blob content` {
+		t.Errorf(`expected the content after the offset to be uploaded, got %q`, blobs[digest])
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		t.Errorf(`expected the file to remain open, got %v`, err)
+	}
+
+	// A reader that cannot seek is copied to a temporary file first.
+	digest, err = PushBlob(ctx, io.MultiReader(strings.NewReader(`streamed `), strings.NewReader(`content`)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(blobs[digest]) != `streamed content` {
+		t.Errorf(`unexpected content %q`, blobs[digest])
+	}
+
+	for digest, expect := range map[string]bool{digest: true, `sha256:0000`: false} {
+		ok, err := HasBlob(ctx, digest)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ok != expect {
+			t.Errorf(`expected HasBlob(%v) to be %v`, digest, expect)
+		}
+	}
+}
+
+func TestChatStopWhen(t *testing.T) {
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.Write([]byte(`{"message":{"role":"assistant","content":"FINAL: 42","tool_calls":[` +
+			`{"function":{"name":"answer","arguments":{}}}]},"done":true}`))
+	}))
+	defer srv.Close()
+
+	var calls atomic.Int32
+	answer, err := tool.New(tool.Name(`answer`), tool.Description(`answers the question`),
+		tool.Func(func(q struct{}) int { calls.Add(1); return 42 }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := With(context.Background(), Host(srv.URL), Model(`llama3.1`))
+	rsp, err := Chat(ctx, chat.User(`what is the answer?`), chat.Toolkit(toolkit.New(answer)),
+		chat.StopWhen(func(rsp *protocol.Response) bool { return strings.HasPrefix(rsp.Message.Content, `FINAL:`) }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rsp.Message.Content != `FINAL: 42` || len(rsp.Message.ToolCalls) != 1 {
+		t.Errorf(`expected the response that satisfied the predicate, got %#v`, rsp)
+	}
+	if n := requests.Load(); n != 1 {
+		t.Errorf(`expected one request, got %v`, n)
+	}
+	if n := calls.Load(); n != 0 {
+		t.Errorf(`expected no tool calls after the predicate matched, got %v`, n)
+	}
+}
+
+func TestChatRequireContent(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"message":{"role":"assistant","content":" \n\t "},"done":true}`))
+	}))
+	defer srv.Close()
+
+	ctx := With(context.Background(), Host(srv.URL), Model(`llama3.1`))
+	_, err := Chat(ctx, chat.User(`hi`))
+	if err != nil {
+		t.Errorf(`expected a blank response to be accepted without RequireContent, got %v`, err)
+	}
+	rsp, err := Chat(ctx, chat.User(`hi`), chat.RequireContent())
+	if !errors.Is(err, chat.ErrEmptyResponse) {
+		t.Errorf(`expected ErrEmptyResponse, got %v`, err)
+	}
+	if rsp == nil || rsp.Message.Content != " \n\t " {
+		t.Errorf(`expected the blank response with the error, got %#v`, rsp)
+	}
+}
+
+func TestChatStop(t *testing.T) {
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.Write([]byte(`{"message":{"role":"assistant","tool_calls":[{"function":{"name":"done","arguments":{}}}]},"done":true}`))
+	}))
+	defer srv.Close()
+
+	done, err := tool.New(tool.Name(`done`), tool.Description(`ends the chat`),
+		tool.Func(func(q struct{}) (string, error) { return ``, chat.Stop{} }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := With(context.Background(), Host(srv.URL), Model(`llama3.1`))
+	rsp, transcript, err := ChatTranscript(ctx, chat.User(`finish up`), chat.Toolkit(toolkit.New(done)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(transcript) != 0 {
+		t.Errorf(`expected the stopped tool call to be left out of the transcript, got %#v`, transcript)
+	}
+	if len(rsp.Message.ToolCalls) != 1 || rsp.Message.ToolCalls[0].Function.Name != `done` {
+		t.Errorf(`expected the response with the tool call, got %#v`, rsp)
+	}
+	if n := requests.Load(); n != 1 {
+		t.Errorf(`expected the tool result not to be sent, got %v requests`, n)
+	}
+}
+
+func TestChatTranscript(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req chat.Request
+		json.NewDecoder(r.Body).Decode(&req)
+		if len(req.Messages) > 1 {
+			w.Write([]byte(`{"message":{"role":"assistant","content":"It is sunny."},"done":true}`))
+			return
+		}
+		w.Write([]byte(`{"message":{"role":"assistant","tool_calls":[` +
+			`{"function":{"name":"weather","arguments":{"city":"Paris"}}}]},"done":true}`))
+	}))
+	defer srv.Close()
+
+	weather, err := tool.New(tool.Name(`weather`), tool.Description(`reports the weather`),
+		tool.Func(func(q struct {
+			City string `json:"city" use:"the city to report on"`
+		}) string {
+			return `sunny in ` + q.City
+		}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := With(context.Background(), Host(srv.URL), Model(`llama3.1`))
+	rsp, transcript, err := ChatTranscript(ctx, chat.User(`What is the weather in Paris?`), chat.Toolkit(toolkit.New(weather)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rsp.Message.Content != `It is sunny.` {
+		t.Errorf(`unexpected response %#v`, rsp)
+	}
+	if len(transcript) != 2 {
+		t.Fatalf(`expected a tool call and a tool result, got %#v`, transcript)
+	}
+	if transcript[0].Role != protocol.ASSISTANT || len(transcript[0].ToolCalls) != 1 ||
+		transcript[0].ToolCalls[0].Function.Name != `weather` {
+		t.Errorf(`expected the tool call from the model, got %#v`, transcript[0])
+	}
+	if transcript[1].Role != protocol.TOOL || transcript[1].ToolName != `weather` || transcript[1].Content != `"sunny in Paris"` {
+		t.Errorf(`expected the result of the tool, got %#v`, transcript[1])
+	}
+}
+
+func TestEmbedLegacy(t *testing.T) {
+	var paths []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		var req struct {
+			Model  string `json:"model"`
+			Prompt string `json:"prompt"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		switch {
+		case req.Model == `missing`:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"error":"model \"missing\" not found, try pulling it first"}`))
+		case r.URL.Path == `/api/embeddings`:
+			w.Write([]byte(fmt.Sprintf(`{"embedding":[%v,0.5]}`, len(req.Prompt))))
+		default:
+			http.NotFound(w, r) // an older Ollama without /api/embed.
+		}
+	}))
+	defer srv.Close()
+	ctx := With(context.Background(), Host(srv.URL), Model(`nomic-embed-text`))
+
+	vec, err := EmbedLegacy(ctx, ``, `abc`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(vec, []float32{3, 0.5}) || !slices.Equal(paths, []string{`/api/embeddings`}) {
+		t.Errorf(`unexpected embedding %v from %q`, vec, paths)
+	}
+
+	paths = nil
+	_, err = Embed(ctx, embed.Input(`a`, `bb`))
+	var oerr *Error
+	if !errors.As(err, &oerr) || oerr.StatusCode != http.StatusNotFound {
+		t.Errorf(`expected a 404 without AllowLegacyFallback, got %v`, err)
+	}
+
+	paths = nil
+	rsp, err := Embed(ctx, embed.Input(`a`, `bb`), embed.AllowLegacyFallback())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rsp.Embeddings) != 2 || rsp.Embeddings[1][0] != 2 {
+		t.Errorf(`unexpected embeddings %v`, rsp.Embeddings)
+	}
+	if expect := []string{`/api/embed`, `/api/embeddings`, `/api/embeddings`}; !slices.Equal(paths, expect) {
+		t.Errorf(`expected requests to %q, got %q`, expect, paths)
+	}
+
+	paths = nil
+	_, err = Embed(ctx, embed.Model(`missing`), embed.Input(`a`, `bb`), embed.AllowLegacyFallback())
+	if !errors.As(err, &oerr) || !oerr.IsModelNotFound() {
+		t.Errorf(`expected the model not found error, got %v`, err)
+	}
+	if !slices.Equal(paths, []string{`/api/embed`}) {
+		t.Errorf(`expected no fallback for a missing model, got requests to %q`, paths)
+	}
+}
@@ -0,0 +1,85 @@
+// Package create details how to create a model request for the Ollama API, either from a Modelfile or from a base model
+// with overrides.
+package create
+
+// From specifies the base model to create the new model from, such as `llama3.1:latest`.
+func From(base string) Option { return func(r *Request) { r.From = base } }
+
+// Modelfile provides the contents of a Modelfile that describes the new model.  This is the only way to create a model
+// with older versions of Ollama.
+//
+// See https://github.com/ollama/ollama/blob/main/docs/modelfile.md
+func Modelfile(modelfile string) Option { return func(r *Request) { r.Modelfile = modelfile } }
+
+// System specifies the system prompt for the new model, replacing the system prompt of the base model.
+func System(system string) Option { return func(r *Request) { r.System = system } }
+
+// Template specifies the prompt template for the new model, replacing the template of the base model.
+func Template(template string) Option { return func(r *Request) { r.Template = template } }
+
+// License specifies the license of the new model.
+func License(license string) Option { return func(r *Request) { r.License = license } }
+
+// Parameter sets a model parameter, such as "temperature" or "num_ctx", for the new model.
+//
+// See https://github.com/ollama/ollama/blob/main/docs/modelfile.md#valid-parameters-and-values
+func Parameter(name string, value any) Option {
+	return func(r *Request) {
+		if r.Parameters == nil {
+			r.Parameters = make(map[string]any)
+		}
+		r.Parameters[name] = value
+	}
+}
+
+// Progress provides a function that is called with each status update from Ollama as the model is created.
+func Progress(fn func(*Response)) Option { return func(r *Request) { r.progress = fn } }
+
+// An Option affects the construction of a create request.
+type Option func(*Request)
+
+// Request describes the structure of a create request.  It is not generally necessary to construct this yourself,
+// instead, use the various options provided.
+type Request struct {
+	// Model is the name of the model to create.
+	Model string `json:"model"`
+
+	// From is the name of an existing model to base the new model on.
+	From string `json:"from,omitempty"`
+
+	// Modelfile is the contents of a Modelfile, used by older versions of Ollama.
+	Modelfile string `json:"modelfile,omitempty"`
+
+	// System is the system prompt of the new model.
+	System string `json:"system,omitempty"`
+
+	// Template is the prompt template of the new model.
+	Template string `json:"template,omitempty"`
+
+	// License is the license of the new model.
+	License string `json:"license,omitempty"`
+
+	// Parameters is a map of model parameters for the new model.
+	Parameters map[string]any `json:"parameters,omitempty"`
+
+	// Stream tells Ollama to stream status updates as the model is created.
+	Stream bool `json:"stream"`
+
+	progress func(*Response)
+}
+
+// Progress returns the function provided by the Progress option, or nil.
+func (req *Request) Progress() func(*Response) { return req.progress }
+
+// Response describes a status update from Ollama while creating a model.
+type Response struct {
+	// Status describes what Ollama is doing, such as "using existing layer" or "success".
+	Status string `json:"status"`
+
+	// Digest, Total and Completed describe the progress of transferring a layer, if any.
+	Digest    string `json:"digest,omitempty"`
+	Total     int64  `json:"total,omitempty"`
+	Completed int64  `json:"completed,omitempty"`
+}
+
+// https://github.com/ollama/ollama/blob/main/docs/api.md#create-a-model
@@ -10,7 +10,7 @@ func Model(model string) Option { return func(q *Request) { q.Model = model } }
 // Temperature affects how random the response may be.  A 0.0 temperature should effectively avoid any deviation from the most probable
 // response.  A 1.0 temperature affords some variation in responses.
 func Temperature(temperature float64) Option {
-	return requestOption(`temperature`, temperature)
+	return Set(`temperature`, temperature)
 }
 
 // Input appends one or more inputs to the request.
@@ -18,7 +18,38 @@ func Input(inputs ...string) Option {
 	return func(r *Request) { r.Input = append(r.Input, inputs...) }
 }
 
-func requestOption(name string, value any) Option {
+// BatchSize limits how many inputs are sent in each request by ollama.EmbedAll, which splits the inputs into batches of
+// at most this size.  This does not affect ollama.Embed.
+func BatchSize(n int) Option {
+	return func(r *Request) { r.batchSize = n }
+}
+
+// AllowLegacyFallback lets ollama.Embed and ollama.EmbedAll fall back to the legacy /api/embeddings endpoint, one
+// input at a time, if the Ollama server responds to /api/embed with 404 Not Found.  A 404 because the model was not
+// found is returned as is.
+func AllowLegacyFallback() Option {
+	return func(r *Request) { r.legacyFallback = true }
+}
+
+// Into decodes the embeddings into dst, reusing its slices, instead of allocating new ones for each response.  This
+// reduces allocations when embedding many inputs, since a buffer can be reused from one request to the next, but the
+// embeddings in the response are only valid until dst is reused.
+func Into(dst [][]float32) Option {
+	return func(r *Request) { r.into = dst }
+}
+
+// OnVector streams the embeddings from the response, calling fn with each vector and the index of its input as it is
+// decoded, instead of collecting them into the Embeddings of the response, which is left empty.  This reduces peak
+// memory when embedding thousands of inputs in one request.  The vector is reused for the next embedding once fn
+// returns, so fn must copy it to keep it.
+func OnVector(fn func(index int, vec []float32)) Option {
+	return func(r *Request) { r.onVector = fn }
+}
+
+// Set sets a model parameter, such as "num_ctx" or "seed", for parameters that do not have their own option.
+//
+// See https://github.com/ollama/ollama/blob/main/docs/modelfile.md#valid-parameters-and-values
+func Set(name string, value any) Option {
 	return func(r *Request) {
 		if r.Options == nil {
 			r.Options = make(map[string]any)
@@ -48,6 +79,35 @@ type Request struct {
 
 	// Options is a map of parameters that override the model parameters, such as temperature.
 	Options map[string]any `json:"options,omitempty"`
+
+	batchSize      int
+	legacyFallback bool
+	into           [][]float32
+	onVector       func(int, []float32)
+}
+
+// BatchSize returns the batch size specified by the BatchSize option, or zero if inputs should not be split into batches.
+func (req *Request) BatchSize() int { return req.batchSize }
+
+// Into returns the buffer provided by the Into option, or nil.
+func (req *Request) Into() [][]float32 { return req.into }
+
+// OnVector returns the function provided by the OnVector option, or nil.
+func (req *Request) OnVector() func(index int, vec []float32) { return req.onVector }
+
+// AllowLegacyFallback returns true if the AllowLegacyFallback option was used.
+func (req *Request) AllowLegacyFallback() bool { return req.legacyFallback }
+
+// LegacyRequest describes a request to the legacy /api/embeddings endpoint, which only accepts a single prompt.
+type LegacyRequest struct {
+	Model   string         `json:"model"`
+	Prompt  string         `json:"prompt"`
+	Options map[string]any `json:"options,omitempty"`
+}
+
+// LegacyResponse describes the response from the legacy /api/embeddings endpoint.
+type LegacyResponse struct {
+	Embedding []float32 `json:"embedding"`
 }
 
 type Response struct {
@@ -0,0 +1,189 @@
+package ollama
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/swdunlop/ollama-client/embed"
+)
+
+// An Embedder embeds text using the same model and options each time, which is convenient for pipelines that embed
+// documents and queries for similarity search.  Embedders are safe for concurrent use.
+//
+// This lives in the ollama package, not the embed package, because embedding requires a client.
+type Embedder struct {
+	options []embed.Option
+}
+
+// NewEmbedder constructs an Embedder for the model, applying the options, such as embed.BatchSize or
+// embed.AllowLegacyFallback, to each request.
+func NewEmbedder(model string, options ...embed.Option) *Embedder {
+	return &Embedder{options: append([]embed.Option{embed.Model(model)}, options...)}
+}
+
+// Embed returns one embedding for each text, in order, using EmbedAll with the client from the context.
+func (e *Embedder) Embed(ctx context.Context, texts ...string) ([][]float32, error) {
+	options := append(e.options[:len(e.options):len(e.options)], embed.Input(texts...))
+	rsp, err := EmbedAll(ctx, options...)
+	if err != nil {
+		return nil, err
+	}
+	return rsp.Embeddings, nil
+}
+
+// EmbedStream embeds each input from the channel, in batches of up to embed.BatchSize inputs, sending a result for each
+// to the returned channel in order, which is closed after the inputs are closed, an error, or the context is done.
+// This embeds a corpus with bounded memory, unlike EmbedAll.  If embed.BatchSize is not used, batches of up to 32
+// inputs are sent.
+//
+// A batch is sent as soon as it is full, or when no more inputs are waiting, so a slow producer is not delayed.  If a
+// batch fails, a result with the error is sent for the index of the first input in the batch, and no more are sent.
+//
+// The embed.Into option is rejected with an error result, since each batch would overwrite the embeddings of the last
+// one while they are still waiting to be received.
+func EmbedStream(ctx context.Context, inputs <-chan string, options ...embed.Option) <-chan EmbedResult {
+	req := newRequest[embed.Request](options...)
+	n := req.BatchSize()
+	if n <= 0 {
+		n = defaultStreamBatchSize
+	}
+	results := make(chan EmbedResult, n)
+	go func() {
+		defer close(results)
+		send := func(result EmbedResult) bool {
+			select {
+			case results <- result:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+		if req.Into() != nil {
+			send(EmbedResult{Err: fmt.Errorf(`embed.Into cannot be used with EmbedStream`)})
+			return
+		}
+		index := 0
+		for {
+			batch := make([]string, 0, n)
+			select {
+			case input, ok := <-inputs:
+				if !ok {
+					return
+				}
+				batch = append(batch, input)
+			case <-ctx.Done():
+				return
+			}
+		fill:
+			for len(batch) < n {
+				select {
+				case input, ok := <-inputs:
+					if !ok {
+						break fill
+					}
+					batch = append(batch, input)
+				default:
+					break fill
+				}
+			}
+			next := *req
+			next.Input = batch
+			rsp, err := doEmbed(ctx, &next)
+			if err != nil {
+				send(EmbedResult{Index: index, Err: err})
+				return
+			}
+			for i, embedding := range rsp.Embeddings {
+				if !send(EmbedResult{Index: index + i, Embedding: embedding}) {
+					return
+				}
+			}
+			index += len(batch)
+		}
+	}()
+	return results
+}
+
+// EmbedResult is the embedding of an input from EmbedStream, identified by the position of the input in the stream.
+type EmbedResult struct {
+	Index     int
+	Embedding []float32
+	Err       error
+}
+
+const defaultStreamBatchSize = 32
+
+// decodeEmbeddings decodes an embed response, passing each embedding to fn as it is decoded instead of collecting them
+// in rsp, see embed.OnVector.  Decoders that cannot read tokens, like encoding/json can, decode the whole response first.
+func decodeEmbeddings(dec Decoder, rsp *embed.Response, fn func(int, []float32)) error {
+	tokens, ok := dec.(tokenDecoder)
+	if !ok {
+		err := dec.Decode(rsp)
+		for i, vec := range rsp.Embeddings {
+			fn(i, vec)
+		}
+		rsp.Embeddings = nil
+		return err
+	}
+	if err := expectDelim(tokens, '{'); err != nil {
+		return err
+	}
+	// Other properties are collected and decoded into rsp afterward, so they do not need to be listed here.
+	rest := make(map[string]json.RawMessage, 8)
+	for tokens.More() {
+		tok, err := tokens.Token()
+		if err != nil {
+			return err
+		}
+		key, _ := tok.(string)
+		if key != `embeddings` {
+			var value json.RawMessage
+			if err := tokens.Decode(&value); err != nil {
+				return err
+			}
+			rest[key] = value
+			continue
+		}
+		if err := expectDelim(tokens, '['); err != nil {
+			return err
+		}
+		var vec []float32
+		for i := 0; tokens.More(); i++ {
+			vec = vec[:0]
+			if err := tokens.Decode(&vec); err != nil {
+				return fmt.Errorf(`%w while decoding embedding %v`, err, i)
+			}
+			fn(i, vec)
+		}
+		if err := expectDelim(tokens, ']'); err != nil {
+			return err
+		}
+	}
+	if err := expectDelim(tokens, '}'); err != nil {
+		return err
+	}
+	js, err := json.Marshal(rest)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(js, rsp)
+}
+
+// tokenDecoder is implemented by decoders that can read a JSON value a token at a time, like json.Decoder.
+type tokenDecoder interface {
+	Decoder
+	Token() (json.Token, error)
+	More() bool
+}
+
+func expectDelim(dec tokenDecoder, delim json.Delim) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if tok != delim {
+		return fmt.Errorf(`expected %v in embed response, got %v`, delim, tok)
+	}
+	return nil
+}
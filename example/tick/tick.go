@@ -57,7 +57,6 @@ func main() {
 		// There are a lot of reasons a chat request can fail, including:
 		// - You do not have ollama running.
 		// - You do not have the right model loaded.
-		// - The model called the tool with an invalid timezone.  (This happens easily.)
 		// - The model tried to call a tool that doesn't exist.  (This happens at really low quantizations or high temperatures.)
 		fmt.Fprintln(os.Stderr, `!!`, err.Error())
 	} else {
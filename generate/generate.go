@@ -0,0 +1,107 @@
+// Package generate details how to create a completion request for the Ollama API, which completes a single prompt
+// instead of a chat.
+package generate
+
+import "time"
+
+// Model specifies the model name; this is required by Ollama.
+//
+// See https://github.com/ollama/ollama/blob/main/docs/api.md#model-names
+func Model(model string) Option { return func(r *Request) { r.Model = model } }
+
+// Prompt specifies the prompt to complete.
+func Prompt(prompt string) Option { return func(r *Request) { r.Prompt = prompt } }
+
+// System overrides the system prompt of the model.
+func System(system string) Option { return func(r *Request) { r.System = system } }
+
+// Context continues from the context returned in the final response of a previous request, so the model remembers
+// the previous prompt and completion.  This is how an interactive completion loop keeps a short conversational memory.
+func Context(context []int) Option { return func(r *Request) { r.Context = context } }
+
+// Raw sends the prompt without applying the prompt template of the model.
+func Raw() Option { return func(r *Request) { r.Raw = true } }
+
+// Stream provides a function that is called with each chunk of the response as it is generated.  If the function
+// returns an error, the request is aborted and the error is returned.  The final chunk has Done set and includes the
+// Context for the next request.
+func Stream(fn func(*Response) error) Option { return func(r *Request) { r.stream = fn } }
+
+// KeepAlive specifies how long the model should stay in memory after the request.  A negative duration keeps the model
+// loaded indefinitely, and zero unloads it after the request.
+func KeepAlive(d time.Duration) Option { return func(r *Request) { r.KeepAlive = d.String() } }
+
+// Set sets a model parameter, such as "num_ctx" or "seed", for parameters that do not have their own option.
+//
+// See https://github.com/ollama/ollama/blob/main/docs/modelfile.md#valid-parameters-and-values
+func Set(name string, value any) Option {
+	return func(r *Request) {
+		if r.Options == nil {
+			r.Options = make(map[string]any)
+		}
+		r.Options[name] = value
+	}
+}
+
+// An Option affects the construction of a generate request.
+type Option func(*Request)
+
+// Request describes the structure of a generate request.  It is not generally necessary to construct this yourself,
+// instead, use the various options provided.
+type Request struct {
+	// Model identifies the ollama model name, such as llama3.1:latest
+	Model string `json:"model"`
+
+	// Prompt is the text to complete.
+	Prompt string `json:"prompt"`
+
+	// System overrides the system prompt of the model.
+	System string `json:"system,omitempty"`
+
+	// Context is the context returned by a previous response.
+	Context []int `json:"context,omitempty"`
+
+	// Raw is true if the prompt should not be formatted with the prompt template of the model.
+	Raw bool `json:"raw,omitempty"`
+
+	// Stream tells Ollama to stream the response as it is generated.
+	Stream bool `json:"stream"`
+
+	// KeepAlive, if present, is a Go duration string, such as "5m", indicating how long the model should stay in memory
+	// after the request.  Ollama reads a number as seconds, not nanoseconds, so this is not a time.Duration.
+	KeepAlive string `json:"keep_alive,omitempty"`
+
+	// Options is a map of parameters that override the model parameters, such as temperature.
+	Options map[string]any `json:"options,omitempty"`
+
+	stream func(*Response) error
+}
+
+// StreamFunc returns the function provided by the Stream option, or nil.
+func (req *Request) StreamFunc() func(*Response) error { return req.stream }
+
+// Response describes a response, or a chunk of a streamed response, from Ollama.
+type Response struct {
+	Model     string    `json:"model"`
+	CreatedAt time.Time `json:"created_at"`
+
+	// Response is the generated text, or the next part of it when streaming.
+	Response string `json:"response"`
+
+	// Done is true for the final response.
+	Done       bool   `json:"done"`
+	DoneReason string `json:"done_reason,omitempty"`
+
+	// Context encodes the prompt and completion, and can be passed to the Context option to continue from here.  It
+	// is only provided in the final response.
+	Context []int `json:"context,omitempty"`
+
+	TotalDuration      time.Duration `json:"total_duration,omitempty"`
+	LoadDuration       time.Duration `json:"load_duration,omitempty"`
+	PromptEvalCount    int64         `json:"prompt_eval_count,omitempty"`
+	PromptEvalDuration time.Duration `json:"prompt_eval_duration,omitempty"`
+	EvalCount          int64         `json:"eval_count,omitempty"`
+	EvalDuration       time.Duration `json:"eval_duration,omitempty"`
+}
+
+// https://github.com/ollama/ollama/blob/main/docs/api.md#generate-a-completion
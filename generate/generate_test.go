@@ -0,0 +1,28 @@
+package generate
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestKeepAlive(t *testing.T) {
+	for _, test := range []struct {
+		d      time.Duration
+		expect string
+	}{
+		{5 * time.Minute, `{"model":"llama3.1","prompt":"","stream":false,"keep_alive":"5m0s"}`},
+		{-1, `{"model":"llama3.1","prompt":"","stream":false,"keep_alive":"-1ns"}`},
+		{0, `{"model":"llama3.1","prompt":"","stream":false,"keep_alive":"0s"}`},
+	} {
+		req := Request{Model: `llama3.1`}
+		KeepAlive(test.d)(&req)
+		js, err := json.Marshal(&req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(js) != test.expect {
+			t.Errorf("expected %v\ngot %v", test.expect, string(js))
+		}
+	}
+}
@@ -6,6 +6,7 @@ require (
 	github.com/iancoleman/strcase v0.3.0
 	github.com/markusmobius/go-dateparser v1.2.3
 	github.com/rs/zerolog v1.33.0
+	golang.org/x/image v0.18.0
 )
 
 require (
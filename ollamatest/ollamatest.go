@@ -0,0 +1,183 @@
+// Package ollamatest provides a fake Ollama server for testing code that uses the ollama package, without a real model.
+// Responses are queued in advance, including tool calls, and each request is recorded so tests can check what was sent.
+package ollamatest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+
+	"github.com/swdunlop/ollama-client"
+	"github.com/swdunlop/ollama-client/chat"
+	"github.com/swdunlop/ollama-client/chat/protocol"
+	"github.com/swdunlop/ollama-client/embed"
+)
+
+// NewServer starts a fake Ollama server, which should be closed with Close when the test is done.
+func NewServer() *Server {
+	s := new(Server)
+	s.srv = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	s.URL = s.srv.URL
+	return s
+}
+
+// A Server is a fake Ollama server that answers chat and embed requests with queued responses, in order.  If there are
+// no responses queued for a request, it fails with a 500 Internal Server Error.  Servers are safe for concurrent use.
+type Server struct {
+	// URL is the base URL of the server, suitable for ollama.Host.
+	URL string
+
+	srv      *httptest.Server
+	mu       sync.Mutex
+	chats    []chat.Response
+	embeds   []embed.Response
+	requests []Request
+}
+
+// Close shuts down the server.
+func (s *Server) Close() { s.srv.Close() }
+
+// Context returns a context with an Ollama client that sends requests to the server, see ollama.With.
+func (s *Server) Context(ctx context.Context, options ...ollama.Option) context.Context {
+	return ollama.With(ctx, append([]ollama.Option{ollama.Host(s.URL)}, options...)...)
+}
+
+// Client returns an Ollama client that sends requests to the server.
+func (s *Server) Client(options ...ollama.Option) *ollama.Client {
+	return ollama.New(append([]ollama.Option{ollama.Host(s.URL)}, options...)...)
+}
+
+// Chat queues responses for chat requests.  Responses are marked as done, and messages without a role are from the
+// assistant.
+func (s *Server) Chat(responses ...chat.Response) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.chats = append(s.chats, responses...)
+}
+
+// Reply queues a chat response with an assistant message with the provided content.
+func (s *Server) Reply(content string) {
+	s.Chat(chat.Response{Message: protocol.Message{Role: protocol.ASSISTANT, Content: content}})
+}
+
+// CallTool queues a chat response where the assistant calls the named tool with the arguments, which are encoded as
+// JSON.  This panics if the arguments cannot be encoded.  Since the chat continues after the tool is called, this is
+// usually followed by Reply.
+func (s *Server) CallTool(name string, arguments any) {
+	js, err := json.Marshal(arguments)
+	if err != nil {
+		panic(err)
+	}
+	s.Chat(chat.Response{Message: protocol.Message{
+		Role: protocol.ASSISTANT,
+		ToolCalls: []protocol.ToolCall{{Function: &protocol.ToolCallFunction{
+			Name:      name,
+			Arguments: js,
+		}}},
+	}})
+}
+
+// Embed queues responses for embed requests.
+func (s *Server) Embed(responses ...embed.Response) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.embeds = append(s.embeds, responses...)
+}
+
+// Requests returns the requests received by the server so far, in the order they were received.
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Request(nil), s.requests...)
+}
+
+// A Request is a request received by the server.
+type Request struct {
+	Method string
+	Path   string
+	Body   json.RawMessage
+}
+
+// ChatRequest decodes the body of the request as a chat request.
+func (req Request) ChatRequest() (*protocol.Request, error) {
+	var ret protocol.Request
+	err := json.Unmarshal(req.Body, &ret)
+	if err != nil {
+		return nil, fmt.Errorf(`%w while decoding %v request`, err, req.Path)
+	}
+	return &ret, nil
+}
+
+// EmbedRequest decodes the body of the request as an embed request.
+func (req Request) EmbedRequest() (*embed.Request, error) {
+	var ret embed.Request
+	err := json.Unmarshal(req.Body, &ret)
+	if err != nil {
+		return nil, fmt.Errorf(`%w while decoding %v request`, err, req.Path)
+	}
+	return &ret, nil
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	var content struct {
+		Model string `json:"model"`
+	}
+	_ = json.Unmarshal(body, &content)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests = append(s.requests, Request{Method: r.Method, Path: r.URL.Path, Body: body})
+	switch r.URL.Path {
+	case `/api/chat`:
+		if len(s.chats) == 0 {
+			writeError(w, http.StatusInternalServerError, `no chat responses queued`)
+			return
+		}
+		rsp := s.chats[0]
+		s.chats = s.chats[1:]
+		if rsp.Model == `` {
+			rsp.Model = content.Model
+		}
+		if rsp.Message.Role == `` {
+			rsp.Message.Role = protocol.ASSISTANT
+		}
+		rsp.Done = true
+		writeJSON(w, rsp)
+	case `/api/embed`:
+		if len(s.embeds) == 0 {
+			writeError(w, http.StatusInternalServerError, `no embed responses queued`)
+			return
+		}
+		rsp := s.embeds[0]
+		s.embeds = s.embeds[1:]
+		if rsp.Model == `` {
+			rsp.Model = content.Model
+		}
+		writeJSON(w, rsp)
+	case `/api/version`:
+		writeJSON(w, map[string]string{`version`: `0.0.0`})
+	default:
+		writeError(w, http.StatusNotFound, `404 page not found`)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set(`Content-Type`, `application/json`)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// writeError writes an error the way Ollama does, as a JSON object with an "error" property.
+func writeError(w http.ResponseWriter, status int, msg string) {
+	w.Header().Set(`Content-Type`, `application/json`)
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]string{`error`: msg})
+}
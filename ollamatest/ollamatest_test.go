@@ -0,0 +1,78 @@
+package ollamatest
+
+import (
+	"context"
+	"testing"
+
+	"github.com/swdunlop/ollama-client"
+	"github.com/swdunlop/ollama-client/chat"
+	"github.com/swdunlop/ollama-client/chat/tool"
+	"github.com/swdunlop/ollama-client/chat/toolkit"
+	"github.com/swdunlop/ollama-client/embed"
+)
+
+func TestServerChat(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+	srv.CallTool(`weather`, map[string]any{`city`: `Paris`})
+	srv.Reply(`It is sunny in Paris.`)
+
+	weather, err := tool.New(tool.Name(`weather`), tool.Description(`reports the weather`),
+		tool.Func(func(q struct {
+			City string `json:"city" use:"the city to report on"`
+		}) string {
+			return `sunny in ` + q.City
+		}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := srv.Context(context.Background(), ollama.Model(`llama3.1`))
+	rsp, err := ollama.Chat(ctx, chat.User(`What is the weather in Paris?`), chat.Toolkit(toolkit.New(weather)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rsp.Message.Content != `It is sunny in Paris.` {
+		t.Errorf(`unexpected response %q`, rsp.Message.Content)
+	}
+
+	requests := srv.Requests()
+	if len(requests) != 2 {
+		t.Fatalf(`expected two chat requests, got %v`, len(requests))
+	}
+	req, err := requests[1].ChatRequest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(req.Messages); n != 3 || req.Messages[2].Content != `"sunny in Paris"` {
+		t.Errorf(`expected the tool result to be sent to the model, got %#v`, req.Messages)
+	}
+	if req.Model != `llama3.1` {
+		t.Errorf(`expected the model from the client, got %q`, req.Model)
+	}
+
+	_, err = ollama.Chat(ctx, chat.User(`Again?`))
+	if err == nil {
+		t.Error(`expected an error without a queued response`)
+	}
+}
+
+func TestServerEmbed(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+	srv.Embed(embed.Response{Embeddings: [][]float32{{1, 2}, {3, 4}}})
+
+	rsp, err := ollama.Embed(srv.Context(context.Background()), embed.Model(`nomic-embed-text`), embed.Input(`a`, `b`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rsp.Embeddings) != 2 || rsp.Embeddings[1][0] != 3 || rsp.Model != `nomic-embed-text` {
+		t.Errorf(`unexpected response %#v`, rsp)
+	}
+	req, err := srv.Requests()[0].EmbedRequest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(req.Input) != 2 {
+		t.Errorf(`expected two inputs, got %v`, req.Input)
+	}
+}
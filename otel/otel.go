@@ -0,0 +1,105 @@
+// Package otel traces Ollama requests using OpenTelemetry.  It is a separate module so the OpenTelemetry dependencies
+// are only required by programs that use it.
+package otel
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime"
+	"net/http"
+
+	"github.com/swdunlop/ollama-client"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Trace adds a round trip hook to the client that traces each request as a span from the provided tracer.  The span
+// records the method, URL and model of the request, and the status and token counts of the response.  Streamed
+// responses are passed through as they arrive, so their token counts are not recorded.
+func Trace(tracer trace.Tracer) ollama.Option {
+	return ollama.RoundTripHook(func(next ollama.RoundTrip) ollama.RoundTrip {
+		return func(req *http.Request) (*http.Response, error) {
+			attrs := []attribute.KeyValue{
+				attribute.String(`http.request.method`, req.Method),
+				attribute.String(`url.full`, req.URL.String()),
+			}
+			var content struct {
+				Model  string `json:"model"`
+				Stream bool   `json:"stream"`
+			}
+			if readJSON(req.GetBody, &content) && content.Model != `` {
+				attrs = append(attrs, attribute.String(`ollama.model`, content.Model))
+			}
+			ctx, span := tracer.Start(req.Context(), `ollama `+req.URL.Path,
+				trace.WithSpanKind(trace.SpanKindClient),
+				trace.WithAttributes(attrs...),
+			)
+			defer span.End()
+
+			rsp, err := next(req.WithContext(ctx))
+			if err != nil {
+				span.RecordError(err)
+				span.SetStatus(codes.Error, err.Error())
+				return rsp, err
+			}
+			span.SetAttributes(attribute.Int(`http.response.status_code`, rsp.StatusCode))
+			if rsp.StatusCode < 200 || rsp.StatusCode > 299 {
+				span.SetStatus(codes.Error, rsp.Status)
+				return rsp, nil
+			}
+			if content.Stream || isNDJSON(rsp.Header) {
+				return rsp, nil // streams are not buffered, so their token counts are not recorded.
+			}
+			body, err := peekBody(rsp)
+			if err != nil {
+				span.RecordError(err)
+				span.SetStatus(codes.Error, err.Error())
+				return nil, err
+			}
+			var counts struct {
+				PromptEvalCount int64 `json:"prompt_eval_count"`
+				EvalCount       int64 `json:"eval_count"`
+			}
+			if readJSON(body, &counts) {
+				span.SetAttributes(
+					attribute.Int64(`ollama.prompt_eval_count`, counts.PromptEvalCount),
+					attribute.Int64(`ollama.eval_count`, counts.EvalCount),
+				)
+			}
+			return rsp, nil
+		}
+	})
+}
+
+// readJSON decodes the content from a body, returning false if there was no body or it could not be decoded.
+func readJSON(getBody func() (io.ReadCloser, error), v any) bool {
+	if getBody == nil {
+		return false
+	}
+	body, err := getBody()
+	if err != nil {
+		return false
+	}
+	defer body.Close()
+	return json.NewDecoder(body).Decode(v) == nil
+}
+
+// isNDJSON returns true if the content type of a response is newline delimited JSON, which Ollama uses for streams.
+func isNDJSON(header http.Header) bool {
+	mediaType, _, _ := mime.ParseMediaType(header.Get(`Content-Type`))
+	return mediaType == `application/x-ndjson`
+}
+
+// peekBody reads the response body and replaces it with a copy, returning a function that provides another copy.  If
+// the body cannot be read, it is closed and the error is returned.
+func peekBody(rsp *http.Response) (func() (io.ReadCloser, error), error) {
+	content, err := io.ReadAll(rsp.Body)
+	rsp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	rsp.Body = io.NopCloser(bytes.NewReader(content))
+	return func() (io.ReadCloser, error) { return io.NopCloser(bytes.NewReader(content)), nil }, nil
+}
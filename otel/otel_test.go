@@ -0,0 +1,110 @@
+package otel
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/swdunlop/ollama-client"
+	"github.com/swdunlop/ollama-client/chat"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestTrace(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(`Content-Type`, `application/json`)
+		w.Write([]byte(`{"message":{"role":"assistant","content":"hi"},"done":true,"prompt_eval_count":12,"eval_count":3}`))
+	}))
+	defer srv.Close()
+
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	ctx := ollama.With(context.Background(), ollama.Host(srv.URL), ollama.Model(`llama3.1`),
+		Trace(provider.Tracer(`test`)))
+	rsp, err := ollama.Chat(ctx, chat.User(`hello`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rsp.Message.Content != `hi` {
+		t.Errorf(`unexpected response %q`, rsp.Message.Content)
+	}
+
+	spans := recorder.Ended()
+	if len(spans) != 1 {
+		t.Fatalf(`expected one span, got %v`, len(spans))
+	}
+	if name := spans[0].Name(); name != `ollama /api/chat` {
+		t.Errorf(`unexpected span name %q`, name)
+	}
+	attrs := make(map[attribute.Key]attribute.Value)
+	for _, attr := range spans[0].Attributes() {
+		attrs[attr.Key] = attr.Value
+	}
+	for key, expect := range map[attribute.Key]attribute.Value{
+		`ollama.model`:              attribute.StringValue(`llama3.1`),
+		`http.response.status_code`: attribute.IntValue(200),
+		`ollama.prompt_eval_count`:  attribute.Int64Value(12),
+		`ollama.eval_count`:         attribute.Int64Value(3),
+	} {
+		if attrs[key] != expect {
+			t.Errorf(`expected %v to be %v, got %v`, key, expect.Emit(), attrs[key].Emit())
+		}
+	}
+}
+
+func TestTraceStream(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(`Content-Type`, `application/x-ndjson`)
+		w.Write([]byte("{\"message\":{\"role\":\"assistant\",\"content\":\"h\"},\"eval_count\":1}\n"))
+		w.(http.Flusher).Flush()
+		<-r.Context().Done() // the rest of the stream never arrives, so it must not be buffered.
+	}))
+	defer srv.Close()
+
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	ctx, cancel := context.WithCancel(context.Background())
+	ctx = ollama.With(ctx, ollama.Host(srv.URL), ollama.Model(`llama3.1`), Trace(provider.Tracer(`test`)))
+	_, err := ollama.Chat(ctx, chat.User(`hello`), chat.StreamHook(func(ctx context.Context, delta *chat.Response) error {
+		cancel()
+		return nil
+	}))
+	if err == nil {
+		t.Error(`expected the canceled stream to fail`)
+	}
+	spans := recorder.Ended()
+	if len(spans) != 1 {
+		t.Fatalf(`expected one span, got %v`, len(spans))
+	}
+	for _, attr := range spans[0].Attributes() {
+		if attr.Key == `ollama.eval_count` {
+			t.Errorf(`expected no token counts for a stream, got %v`, attr.Value.Emit())
+		}
+	}
+}
+
+func TestTraceBrokenResponse(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(`Content-Type`, `application/json`)
+		w.Header().Set(`Content-Length`, `100`)
+		w.Write([]byte(`{"message":`)) // the connection closes before the rest of the body.
+	}))
+	defer srv.Close()
+
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	ctx := ollama.With(context.Background(), ollama.Host(srv.URL), ollama.Model(`llama3.1`),
+		Trace(provider.Tracer(`test`)))
+	_, err := ollama.Chat(ctx, chat.User(`hello`))
+	if err == nil {
+		t.Fatal(`expected an error for a truncated response`)
+	}
+	spans := recorder.Ended()
+	if len(spans) != 1 || spans[0].Status().Code != codes.Error {
+		t.Errorf(`expected one failed span, got %v`, spans)
+	}
+}
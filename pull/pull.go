@@ -0,0 +1,57 @@
+// Package pull details how to pull a model from a registry, such as ollama.com, with the Ollama API.
+package pull
+
+// Insecure allows pulling from a registry over HTTP or with an unverified TLS certificate.  This should only be used
+// for development registries.
+func Insecure() Option { return func(r *Request) { r.Insecure = true } }
+
+// Progress provides a function that is called with each status update from Ollama as the model is pulled.
+func Progress(fn func(*Response)) Option { return func(r *Request) { r.progress = fn } }
+
+// OnLayer provides a function that is called for each layer of the model as its download starts and again when it is
+// done, identified by its digest.  This is useful for tracking which layers are already present.
+func OnLayer(fn func(digest string, done bool)) Option { return func(r *Request) { r.onLayer = fn } }
+
+// An Option affects the construction of a pull request.
+type Option func(*Request)
+
+// Request describes the structure of a pull request.  It is not generally necessary to construct this yourself,
+// instead, use the various options provided.
+type Request struct {
+	// Model is the name of the model to pull.
+	Model string `json:"model"`
+
+	// Insecure allows pulling from a registry without TLS verification.
+	Insecure bool `json:"insecure,omitempty"`
+
+	// Stream tells Ollama to stream status updates as the model is pulled.
+	Stream bool `json:"stream"`
+
+	progress func(*Response)
+	onLayer  func(string, bool)
+}
+
+// Progress returns the function provided by the Progress option, or nil.
+func (req *Request) Progress() func(*Response) { return req.progress }
+
+// OnLayer returns the function provided by the OnLayer option, or nil.
+func (req *Request) OnLayer() func(string, bool) { return req.onLayer }
+
+// Response describes a status update from Ollama while pulling a model.
+type Response struct {
+	// Status describes what Ollama is doing, such as "pulling manifest", "verifying sha256 digest" or "success".
+	Status string `json:"status"`
+
+	// Digest, Total and Completed describe the progress of downloading a layer, if any.
+	Digest    string `json:"digest,omitempty"`
+	Total     int64  `json:"total,omitempty"`
+	Completed int64  `json:"completed,omitempty"`
+}
+
+// Status values sent by Ollama after all layers are downloaded.
+const (
+	StatusVerifying = `verifying sha256 digest`
+	StatusSuccess   = `success`
+)
+
+// https://github.com/ollama/ollama/blob/main/docs/api.md#pull-a-model
@@ -0,0 +1,140 @@
+package ollama
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Record adds a round trip hook that writes each request and its response to a JSON file in dir, named after the time
+// of the request and a hash of its content, so they can be replayed later with Replay to reproduce a bug or as a
+// regression test without an Ollama server.  The directory must already exist.  Streamed responses are read in full
+// before they are returned, so streaming callbacks are not called until the response is complete.
+func Record(dir string) Option {
+	return RoundTripHook(func(next RoundTrip) RoundTrip {
+		return func(req *http.Request) (*http.Response, error) {
+			body, err := stealBody(&req.Body)
+			if err != nil {
+				return nil, err
+			}
+			rsp, err := next(req)
+			if err != nil {
+				return nil, err
+			}
+			// An incomplete response is not recorded, since replaying it would not reproduce the failure.
+			content, err := stealBody(&rsp.Body)
+			if err != nil {
+				return nil, fmt.Errorf(`%w while recording %v %v`, err, req.Method, req.URL.RequestURI())
+			}
+			rec := recording{
+				Method:      req.Method,
+				URI:         req.URL.RequestURI(),
+				Request:     string(body),
+				Status:      rsp.StatusCode,
+				ContentType: rsp.Header.Get(`Content-Type`),
+				Response:    string(content),
+			}
+			js, err := json.MarshalIndent(rec, ``, `  `)
+			if err != nil {
+				return nil, err
+			}
+			name := time.Now().UTC().Format(`20060102T150405.000000000Z`) + `-` + recordingHash(req.Method, rec.URI, body) + `.json`
+			err = os.WriteFile(filepath.Join(dir, name), js, 0o644)
+			if err != nil {
+				return nil, fmt.Errorf(`%w while recording %v %v`, err, req.Method, rec.URI)
+			}
+			return rsp, nil
+		}
+	})
+}
+
+// Replay adds a round trip hook that answers requests with the responses recorded by Record in dir, instead of sending
+// them to the Ollama server.  Requests are matched by a hash of their method, path and content, so the request must be
+// exactly the same as the one that was recorded.  If the same request was recorded more than once, the recordings are
+// replayed in the order they were recorded, repeating the last one.  Requests that were not recorded fail with
+// ErrNotRecorded.
+//
+// Clients derived from this one with Apply or With share the same position in each sequence of recordings.
+func Replay(dir string) Option {
+	var mu sync.Mutex
+	replayed := make(map[string]int)
+	return RoundTripHook(func(RoundTrip) RoundTrip {
+		return func(req *http.Request) (*http.Response, error) {
+			uri := req.URL.RequestURI()
+			body, err := stealBody(&req.Body)
+			if err != nil {
+				return nil, err
+			}
+			hash := recordingHash(req.Method, uri, body)
+			names, err := filepath.Glob(filepath.Join(dir, `*-`+hash+`.json`))
+			if err != nil {
+				return nil, err
+			}
+			if len(names) == 0 {
+				return nil, fmt.Errorf(`%w: %v %v in %q`, ErrNotRecorded, req.Method, uri, dir)
+			}
+			slices.Sort(names)
+			mu.Lock()
+			i := min(replayed[hash], len(names)-1)
+			replayed[hash]++
+			mu.Unlock()
+
+			js, err := os.ReadFile(names[i])
+			if err != nil {
+				return nil, err
+			}
+			var rec recording
+			err = json.Unmarshal(js, &rec)
+			if err != nil {
+				return nil, fmt.Errorf(`%w while reading recording %q`, err, names[i])
+			}
+			header := make(http.Header)
+			if rec.ContentType != `` {
+				header.Set(`Content-Type`, rec.ContentType)
+			}
+			return &http.Response{
+				Status:        fmt.Sprintf(`%d %s`, rec.Status, http.StatusText(rec.Status)),
+				StatusCode:    rec.Status,
+				Proto:         `HTTP/1.1`,
+				ProtoMajor:    1,
+				ProtoMinor:    1,
+				Header:        header,
+				Body:          io.NopCloser(strings.NewReader(rec.Response)),
+				ContentLength: int64(len(rec.Response)),
+				Request:       req,
+			}, nil
+		}
+	})
+}
+
+// ErrNotRecorded is returned by clients using Replay for requests that were not recorded.
+var ErrNotRecorded = errors.New(`request was not recorded`)
+
+// recording is the content of a file written by Record.  The bodies are strings, not JSON, since streamed responses are
+// a sequence of JSON objects.
+type recording struct {
+	Method      string `json:"method"`
+	URI         string `json:"uri"`
+	Request     string `json:"request,omitempty"`
+	Status      int    `json:"status"`
+	ContentType string `json:"content_type,omitempty"`
+	Response    string `json:"response"`
+}
+
+// recordingHash identifies a request by its method, URI and body.
+func recordingHash(method, uri string, body []byte) string {
+	hash := sha256.New()
+	_, _ = fmt.Fprintf(hash, "%s %s\n", method, uri)
+	_, _ = hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil))[:16]
+}
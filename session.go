@@ -0,0 +1,89 @@
+package ollama
+
+import (
+	"context"
+	"slices"
+
+	"github.com/swdunlop/ollama-client/chat"
+	"github.com/swdunlop/ollama-client/chat/message"
+	"github.com/swdunlop/ollama-client/chat/protocol"
+)
+
+// A Session accumulates the history of a conversation with a model, so each request includes the messages that came
+// before it.  The zero value is an empty session, ready to use.  Sessions are not safe for concurrent use.
+//
+// This lives in the ollama package, not the chat package, because sending requires a client.
+type Session struct {
+	// Messages is the history of the session, including messages from the model and tools.
+	Messages []protocol.Message
+}
+
+// System adds a message with the system role to the session history.
+func (s *Session) System(content string, options ...message.Option) {
+	s.Message(protocol.SYSTEM, content, options...)
+}
+
+// User adds a message with the user role to the session history.
+func (s *Session) User(content string, options ...message.Option) {
+	s.Message(protocol.USER, content, options...)
+}
+
+// Message adds a message to the session history.
+func (s *Session) Message(role chat.Role, content string, options ...message.Option) {
+	m := protocol.Message{Role: role, Content: content}
+	for _, option := range options {
+		option(&m)
+	}
+	s.Messages = append(s.Messages, m)
+}
+
+// Clone returns a deep copy of the session, so each can continue the conversation differently without affecting the
+// other, such as when exploring several continuations from a common prefix.
+func (s *Session) Clone() *Session {
+	cp := &Session{Messages: slices.Clone(s.Messages)}
+	for i, m := range cp.Messages {
+		m.Images = slices.Clone(m.Images)
+		m.ToolCalls = slices.Clone(m.ToolCalls)
+		for j, call := range m.ToolCalls {
+			if call.Function != nil {
+				function := *call.Function
+				function.Arguments = slices.Clone(function.Arguments)
+				m.ToolCalls[j].Function = &function
+			}
+		}
+		cp.Messages[i] = m
+	}
+	return cp
+}
+
+// Reset clears the session history, except for the system messages at the start of it.
+func (s *Session) Reset() {
+	n := 0
+	for n < len(s.Messages) && s.Messages[n].Role == protocol.SYSTEM {
+		n++
+	}
+	clear(s.Messages[n:])
+	s.Messages = s.Messages[:n]
+}
+
+// Trim drops the oldest messages from the session history until it fits within maxTokens, using chat.TrimHistory.
+func (s *Session) Trim(maxTokens int, count func(string) int) {
+	s.Messages = chat.TrimHistory(s.Messages, maxTokens, count)
+}
+
+// Send does a chat request, like Chat, with the session history followed by any messages added by the options.  If the
+// request succeeds, those messages, any tool calls and their results, and the response message are appended to the
+// history.  If it fails, the history is left unchanged.
+//
+// Only the messages are kept between turns, so other options, like chat.Format, chat.JSON or chat.Tools, only apply to
+// the Send they are passed to.  This lets one turn ask for JSON and the next for freeform text.
+func (s *Session) Send(ctx context.Context, options ...chat.Option) (*chat.Response, error) {
+	req := newRequest[chat.Request](options...)
+	req.Messages = append(append([]protocol.Message(nil), s.Messages...), req.Messages...)
+	rsp, err := doChat(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	s.Messages = append(req.Messages, rsp.Message)
+	return rsp, nil
+}
@@ -0,0 +1,108 @@
+package ollama
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/swdunlop/ollama-client/chat"
+	"github.com/swdunlop/ollama-client/chat/message"
+	"github.com/swdunlop/ollama-client/chat/protocol"
+	"github.com/swdunlop/ollama-client/chat/tool"
+	"github.com/swdunlop/ollama-client/chat/toolkit"
+)
+
+func TestSessionClone(t *testing.T) {
+	var s Session
+	s.System(`be brief`)
+	s.User(`hi`)
+	s.Message(protocol.ASSISTANT, ``, message.ToolCalls(protocol.ToolCall{Function: &protocol.ToolCallFunction{
+		Name:      `tick`,
+		Arguments: json.RawMessage(`{"tz":"UTC"}`),
+	}}))
+
+	cp := s.Clone()
+	cp.Messages[1].Content = `hello`
+	cp.Messages[2].ToolCalls[0].Function.Name = `tock`
+	cp.Messages[2].ToolCalls[0].Function.Arguments[1] = 'X'
+	cp.User(`another branch`)
+
+	if s.Messages[1].Content != `hi` || len(s.Messages) != 3 {
+		t.Errorf(`expected the original messages to be unchanged, got %#v`, s.Messages)
+	}
+	if call := s.Messages[2].ToolCalls[0].Function; call.Name != `tick` || string(call.Arguments) != `{"tz":"UTC"}` {
+		t.Errorf(`expected the original tool call to be unchanged, got %v %s`, call.Name, call.Arguments)
+	}
+
+	cp.Reset()
+	if len(cp.Messages) != 1 || cp.Messages[0].Content != `be brief` {
+		t.Errorf(`expected only the system prompt after a reset, got %#v`, cp.Messages)
+	}
+	if len(s.Messages) != 3 {
+		t.Errorf(`expected the original to keep its history after resetting the clone`)
+	}
+}
+
+func TestSessionFormatPerSend(t *testing.T) {
+	var formats []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req protocol.Request
+		json.NewDecoder(r.Body).Decode(&req)
+		formats = append(formats, string(req.Format))
+		w.Write([]byte(`{"message":{"role":"assistant","content":"{}"},"done":true}`))
+	}))
+	defer srv.Close()
+
+	ctx := With(context.Background(), Host(srv.URL), Model(`llama3.1`))
+	var s Session
+	for _, options := range [][]chat.Option{
+		{chat.User(`as JSON`), chat.JSON()},
+		{chat.User(`as text`)},
+	} {
+		if _, err := s.Send(ctx, options...); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(formats) != 2 || formats[0] != `json` || formats[1] != `` {
+		t.Errorf(`expected only the first turn to ask for JSON, got %q`, formats)
+	}
+}
+
+func TestSessionStop(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"message":{"role":"assistant","tool_calls":[` +
+			`{"function":{"name":"note","arguments":{}}},` +
+			`{"function":{"name":"done","arguments":{}}}]},"done":true}`))
+	}))
+	defer srv.Close()
+
+	note, err := tool.New(tool.Name(`note`), tool.Description(`takes a note`),
+		tool.Func(func(q struct{}) string { return `noted` }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	done, err := tool.New(tool.Name(`done`), tool.Description(`ends the chat`),
+		tool.Func(func(q struct{}) (string, error) { return ``, chat.Stop{} }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := With(context.Background(), Host(srv.URL), Model(`llama3.1`))
+	var s Session
+	s.System(`be brief`)
+	rsp, err := s.Send(ctx, chat.User(`finish up`), chat.Toolkit(toolkit.New(note, done)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s.Messages) != 3 {
+		t.Fatalf(`expected the system, user and assistant messages, got %#v`, s.Messages)
+	}
+	if s.Messages[0].Content != `be brief` || s.Messages[1].Content != `finish up` {
+		t.Errorf(`unexpected history %#v`, s.Messages[:2])
+	}
+	if last := s.Messages[2]; last.Role != protocol.ASSISTANT || len(last.ToolCalls) != 2 ||
+		len(rsp.Message.ToolCalls) != 2 {
+		t.Errorf(`expected the response with the tool calls to end the history, got %#v`, last)
+	}
+}
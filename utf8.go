@@ -0,0 +1,108 @@
+package ollama
+
+import (
+	"encoding/hex"
+	"errors"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// rawText is a JSON string that is decoded without replacing invalid UTF-8 with U+FFFD, like encoding/json does, so
+// a rune that Ollama splits between two streamed chunks can be spliced back together by a runeSplicer.
+type rawText []byte
+
+func (txt *rawText) UnmarshalJSON(js []byte) error {
+	if string(js) == `null` {
+		*txt = nil
+		return nil
+	}
+	if len(js) < 2 || js[0] != '"' || js[len(js)-1] != '"' {
+		return errors.New(`expected a JSON string`)
+	}
+	js = js[1 : len(js)-1]
+	ret := make([]byte, 0, len(js))
+	for i := 0; i < len(js); i++ {
+		if js[i] != '\\' {
+			ret = append(ret, js[i])
+			continue
+		}
+		i++
+		if i >= len(js) {
+			return errors.New(`truncated escape in JSON string`)
+		}
+		switch js[i] {
+		case '"', '\\', '/':
+			ret = append(ret, js[i])
+		case 'b':
+			ret = append(ret, '\b')
+		case 'f':
+			ret = append(ret, '\f')
+		case 'n':
+			ret = append(ret, '\n')
+		case 'r':
+			ret = append(ret, '\r')
+		case 't':
+			ret = append(ret, '\t')
+		case 'u':
+			r, ok := parseEscape(js[i+1:])
+			if !ok {
+				return errors.New(`invalid unicode escape in JSON string`)
+			}
+			i += 4
+			if utf16.IsSurrogate(r) && len(js) > i+2 && js[i+1] == '\\' && js[i+2] == 'u' {
+				if r2, ok := parseEscape(js[i+3:]); ok {
+					if pair := utf16.DecodeRune(r, r2); pair != utf8.RuneError {
+						r, i = pair, i+6
+					}
+				}
+			}
+			ret = utf8.AppendRune(ret, r)
+		default:
+			return errors.New(`invalid escape in JSON string`)
+		}
+	}
+	*txt = ret
+	return nil
+}
+
+// parseEscape parses the four hex digits of a \u escape.
+func parseEscape(js []byte) (rune, bool) {
+	if len(js) < 4 {
+		return 0, false
+	}
+	var b [2]byte
+	_, err := hex.Decode(b[:], js[:4])
+	if err != nil {
+		return 0, false
+	}
+	return rune(b[0])<<8 | rune(b[1]), true
+}
+
+// A runeSplicer holds back an incomplete rune at the end of each streamed chunk until the rest of it arrives, so
+// callers only see complete UTF-8 sequences.
+type runeSplicer struct{ pending []byte }
+
+// splice returns the pending bytes and p, up to the last complete rune, keeping any incomplete rune for later.
+func (rs *runeSplicer) splice(p []byte) []byte {
+	buf := append(rs.pending, p...)
+	n := len(buf)
+	// A rune is at most utf8.UTFMax bytes, so only the last few bytes can be the start of an incomplete rune.
+	for i := len(buf) - 1; i >= 0 && i >= len(buf)-utf8.UTFMax; i-- {
+		if !utf8.RuneStart(buf[i]) {
+			continue
+		}
+		if !utf8.FullRune(buf[i:]) {
+			n = i
+		}
+		break
+	}
+	rs.pending = append([]byte(nil), buf[n:]...)
+	return buf[:n]
+}
+
+// flush returns any pending bytes at the end of a stream, even if they are not a complete rune.
+func (rs *runeSplicer) flush() []byte {
+	ret := rs.pending
+	rs.pending = nil
+	return ret
+}
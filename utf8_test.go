@@ -0,0 +1,66 @@
+package ollama
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"github.com/swdunlop/ollama-client/chat"
+)
+
+func TestRuneSplicer(t *testing.T) {
+	var rs runeSplicer
+	var chunks []string
+	for _, p := range []string{"price: \xe2\x82", "\xac5", "\xf0\x9f", "\x98", "\x80!"} {
+		chunks = append(chunks, string(rs.splice([]byte(p))))
+	}
+	chunks = append(chunks, string(rs.flush()))
+	if expect := []string{`price: `, `€5`, ``, ``, `😀!`, ``}; !slices.Equal(chunks, expect) {
+		t.Errorf(`expected %q, got %q`, expect, chunks)
+	}
+}
+
+func TestRawText(t *testing.T) {
+	for _, test := range []struct{ js, expect string }{
+		{`"plain"`, `plain`},
+		{`"tab\there \"quoted\" \\ \/"`, "tab\there \"quoted\" \\ /"},
+		{`"€ 😀"`, `€ 😀`},
+		{"\"split \xe2\x82\"", "split \xe2\x82"},
+	} {
+		var txt rawText
+		err := json.Unmarshal([]byte(test.js), &txt)
+		if err != nil {
+			t.Errorf(`%v while decoding %v`, err, test.js)
+			continue
+		}
+		if string(txt) != test.expect {
+			t.Errorf(`expected %v to decode as %q, got %q`, test.js, test.expect, string(txt))
+		}
+	}
+}
+
+func TestChatReaderSplitRune(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("{\"message\":{\"role\":\"assistant\",\"content\":\"5 \xe2\x82\"}}\n"))
+		w.Write([]byte("{\"message\":{\"role\":\"assistant\",\"content\":\"\xac\"},\"done\":true}\n"))
+	}))
+	defer srv.Close()
+
+	ctx := With(context.Background(), Host(srv.URL), Model(`llama3.1`))
+	r, err := ChatReader(ctx, chat.User(`price?`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	content, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != `5 €` {
+		t.Errorf(`expected "5 €", got %q`, content)
+	}
+}